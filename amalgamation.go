@@ -0,0 +1,107 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"log"
+	"strconv"
+)
+
+// Amalgamation is the template data for one file of single file output.
+type Amalgamation struct {
+	Year    string
+	Header  string    // The header with the extern list, ie syscalls_freebsd.h.
+	Entries []Entry   // The syscall entries defined in this file.
+	Tables  *Syscalls // The syscall tables, only set for the file that defines them.
+}
+
+// amalgamationName returns the name of a single file output file,
+// ie syscalls_freebsd.c.
+func amalgamationName(platform Platform, ext string) string {
+	return "syscalls_" + platform.Name + ext
+}
+
+// shardEntries splits the entries into n contiguous shards of about
+// the same size.
+func shardEntries(entries []Entry, n int) [][]Entry {
+	if n < 1 {
+		n = 1
+	}
+
+	size := (len(entries) + n - 1) / n
+	shards := make([][]Entry, n)
+	for i := 0; i < n; i++ {
+		start := i * size
+		end := start + size
+		if start > len(entries) {
+			start = len(entries)
+		}
+		if end > len(entries) {
+			end = len(entries)
+		}
+		shards[i] = entries[start:end]
+	}
+
+	return shards
+}
+
+// createAmalgamation writes every syscall entry and the syscall tables into
+// syscalls_<os>.c. When more than one shard is requested the entries are
+// spread over syscalls_<os>_1.c through syscalls_<os>_N.c instead and
+// syscalls_<os>.c only holds the tables.
+func createAmalgamation(out *Output, model *Model, opts Options) {
+	t, err := loadTemplate(opts.TemplateDir, model, "amalgamation.txt", "entry.txt", "syscall_table.txt")
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	tables := buildSyscallTables(model.definedSlots())
+	tables.Platform = model.Platform
+
+	// Every entry goes into the same few files, so a syscall defined
+	// twice would be a redefinition of its entry.
+	entries := model.definedEntries()
+	for _, entry := range entries {
+		checkEntry(entry)
+	}
+
+	header := amalgamationName(model.Platform, ".h")
+	files := make(map[string]Amalgamation)
+
+	if opts.Shards <= 1 {
+		files[amalgamationName(model.Platform, ".c")] = Amalgamation{Header: header,
+			Entries: entries,
+			Tables:  &tables}
+	} else {
+		for i, entries := range shardEntries(entries, opts.Shards) {
+			name := amalgamationName(model.Platform, "_"+strconv.Itoa(i+1)+".c")
+			files[name] = Amalgamation{Header: header, Entries: entries}
+		}
+		files[amalgamationName(model.Platform, ".c")] = Amalgamation{Header: header, Tables: &tables}
+	}
+
+	for name, data := range files {
+		data.Year = copyrightYear(out.path(name), opts)
+		tables.Year = data.Year
+
+		err = out.execute(name, t, data)
+		if err != nil {
+			log.Fatal("Can't write amalgamation file: ", err)
+			return
+		}
+	}
+}
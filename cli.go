@@ -0,0 +1,589 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is one of entrygen's subcommands.
+type command struct {
+	Name  string
+	Usage string
+	Help  string
+	Run   func(args []string)
+}
+
+func commands() []command {
+	return []command{
+		{"generate", "generate [flags]", "Generate the syscall entry files, the default command.", runGenerate},
+		{"list", "list [flags]", "Print every syscall in the master file with its number, args and status.", runList},
+		{"show", "show [flags] name", "Print everything entrygen knows about one syscall.", runShow},
+		{"validate", "validate [flags]", "Parse the master file and report arguments without a type mapping.", runValidate},
+		{"diff", "diff [flags]", "Print a diff between the files on disk and what generate would write.", runDiff},
+		{"stats", "stats [flags]", "Print statistics about the parsed syscalls.", runStats},
+		{"export", "export [flags]", "Write the parsed syscall model in a format other tools can read.", runExport},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: entrygen <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.Name, cmd.Help)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun entrygen <command> -h for the flags of a command.\n")
+}
+
+func runCommand(name string, args []string) {
+	if name == "help" || name == "-h" {
+		usage()
+		return
+	}
+
+	for _, cmd := range commands() {
+		if cmd.Name == name {
+			cmd.Run(args)
+			return
+		}
+	}
+
+	usage()
+	log.Fatalf("Unknown command %q", name)
+}
+
+// newFlagSet creates the flag set of a command.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		for _, cmd := range commands() {
+			if cmd.Name == name {
+				fmt.Fprintf(fs.Output(), "Usage: entrygen %s\n\n%s\n\n", cmd.Usage, cmd.Help)
+			}
+		}
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// inputFlags select the master file and which of it's syscalls to use,
+// every command shares them.
+type inputFlags struct {
+	os      *string
+	arch    *string
+	only    *string
+	exclude *string
+	ranges  *string
+	groups  *string
+	input   *string
+	dialect *string
+	format  *string
+	syz     *string
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	return &inputFlags{
+		os:      fs.String("os", "default", "The operating system to generate syscall entry for, help lists them."),
+		arch:    fs.String("arch", runtime.GOARCH, "The architecture to name the generated tables for."),
+		only:    fs.String("only", "", "Only generate these comma separated syscalls, globs and /regexps/ allowed."),
+		exclude: fs.String("exclude", "", "Don't generate these comma separated syscalls, globs and /regexps/ allowed."),
+		ranges:  fs.String("range", "", "Only generate syscalls with numbers in these comma separated ranges, ie 0-200."),
+		groups:  fs.String("group", "", "Only generate syscalls in these comma separated groups, ie net,ipc."),
+		input:   fs.String("input", "", "The syscalls.master file to read, - reads standard input."),
+		dialect: fs.String("dialect", "", "The syntax of the master file, freebsd or xnu. Defaults to the one for -os."),
+		format:  fs.String("input-format", "master", "The format of -input, master or json written by entrygen export."),
+		syz:     fs.String("syz", "", "Comma separated syzkaller .txt descriptions, or directories of them, to enrich arguments with."),
+	}
+}
+
+// backend returns the operating system the flags select. If none was
+// selected it's the one the dialect is for, or the one we are running on.
+func (in *inputFlags) backend() Backend {
+	name := *in.os
+	if name == "help" {
+		printBackends(os.Stdout)
+		os.Exit(0)
+	}
+
+	if name == "default" && *in.dialect != "" {
+		if d, err := lookupDialect(*in.dialect); err == nil {
+			name = d.OS
+		}
+	}
+
+	if name == "default" {
+		log.Printf("No operating system selected, defaulting to: %s", runtime.GOOS)
+		name = runtime.GOOS
+	}
+
+	backend, err := lookupBackend(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return backend
+}
+
+// load parses the master file into the syscall model and enriches it
+// with syzkaller descriptions if any were given.
+func (in *inputFlags) load() *Model {
+	model := in.loadModel()
+
+	if *in.syz != "" {
+		descriptions, err := readSyzDescriptions(splitList(*in.syz))
+		if err != nil {
+			log.Fatal(err)
+		}
+		model.Undescribed = descriptions.enrichModel(model)
+		if len(model.Undescribed) != 0 {
+			log.Printf("%d syscalls have no syzkaller description: %s",
+				len(model.Undescribed), strings.Join(model.Undescribed, ", "))
+		}
+	}
+
+	return model
+}
+
+func (in *inputFlags) loadModel() *Model {
+	filter, err := parseFilter(*in.only, *in.exclude, *in.ranges, *in.groups)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *in.format == "json" {
+		return in.loadJSON(filter)
+	}
+
+	if *in.format != "master" {
+		log.Fatalf("Unknown input format %q, expected master or json", *in.format)
+	}
+
+	backend := in.backend()
+
+	dialectName := *in.dialect
+	if dialectName == "" {
+		dialectName = backend.Dialect
+	}
+
+	dialect, err := lookupDialect(dialectName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the config file into memory. We need the config file to know how
+	// to generate syscall entries. The config file contains number of args
+	// and syscall types, and more.
+	var config []byte
+	if *in.input == "" {
+		config, err = readDefaultMaster(backend.Input)
+	} else {
+		config, err = readMaster(*in.input)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return parseModel(newPlatform(backend.Name, *in.arch), dialect.parseMaster(config), filter)
+}
+
+// loadJSON reads a model written by entrygen export. The platform comes
+// from the file, so -os and -dialect are not used.
+func (in *inputFlags) loadJSON(filter Filter) *Model {
+	if *in.input == "" {
+		log.Fatal("-input-format json needs an -input file")
+	}
+
+	data, err := readMaster(*in.input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	model, err := importModel(data, filter)
+	if err != nil {
+		log.Fatalf("%s: %v", *in.input, err)
+	}
+
+	return model
+}
+
+// outputFlags control how and where output files are generated.
+type outputFlags struct {
+	format       *string
+	goPackage    *string
+	sysMacros    *bool
+	out          *string
+	entryPattern *string
+	templates    *string
+	amalgamate   *bool
+	shards       *int
+	year         *string
+	nextgen      *string
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		format:       fs.String("format", "c", "The output format: c for nextgen, syzlang, trinity, go, rust, printer, repro or docs."),
+		goPackage:    fs.String("go-package", "syscalls", "The package name of the Go wrappers written by -format go."),
+		sysMacros:    fs.Bool("sys-macros", false, "Emit SYS_* constants from <sys/syscall.h> instead of raw syscall numbers."),
+		out:          fs.String("out", "", "The directory to write output files to, defaults to the operating system name."),
+		entryPattern: fs.String("entry-name", "entry_{{name}}.c", "The file name of each entry file, {{name}} is replaced with the syscall name."),
+		templates:    fs.String("templates", "", "Directory with templates that override the embedded defaults."),
+		amalgamate:   fs.Bool("single", false, "Write all entries, the extern list and the tables into one syscalls_<os>.c and .h pair."),
+		shards:       fs.Int("shards", 1, "Spread the entries over this many .c files when -single is used."),
+		year:         fs.String("year", "", "Pin the copyright year, defaults to SOURCE_DATE_EPOCH or the year in existing output files."),
+		nextgen:      fs.String("nextgen", "", "Write output files into the syscall directory of this nextgen checkout."),
+	}
+}
+
+// options checks the output flags and turns them into Options.
+func (o *outputFlags) options(platform Platform) Options {
+	if strings.Contains(*o.entryPattern, "{{name}}") != true {
+		log.Fatalf("Entry file name %q must contain {{name}}", *o.entryPattern)
+	}
+
+	if *o.shards < 1 {
+		log.Fatal("-shards must be at least 1")
+	}
+
+	if *o.out != "" && *o.nextgen != "" {
+		log.Fatal("Only one of -out and -nextgen can be used")
+	}
+
+	year, err := pinnedYear(*o.year)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := lookupFormat(*o.format); err != nil {
+		log.Fatal(err)
+	}
+
+	opts := Options{Format: *o.format,
+		GoPackage:     *o.goPackage,
+		SyscallMacros: *o.sysMacros,
+		OutputDir:     *o.out,
+		EntryPattern:  *o.entryPattern,
+		TemplateDir:   *o.templates,
+		Amalgamate:    *o.amalgamate,
+		Shards:        *o.shards,
+		Year:          year}
+
+	if *o.nextgen != "" {
+		opts.OutputDir = nextgenDir(*o.nextgen, platform)
+	}
+
+	return opts
+}
+
+func runGenerate(args []string) {
+	fs := newFlagSet("generate")
+	in := addInputFlags(fs)
+	output := addOutputFlags(fs)
+	check := fs.Bool("check", false, "Don't write anything, print a diff of every out of date file and exit non-zero if there are any.")
+	dryRun := fs.Bool("dry-run", false, "Print which files would be created, updated or deleted without touching disk.")
+	stdout := fs.String("stdout", "", "Print the generated entry of this syscall, or the table or list, to standard output.")
+	fs.Parse(args)
+
+	modes := 0
+	for _, mode := range []bool{*check, *dryRun, *stdout != ""} {
+		if mode {
+			modes++
+		}
+	}
+
+	if modes > 1 {
+		log.Fatal("Only one of -check, -dry-run and -stdout can be used")
+	}
+
+	model := in.load()
+	opts := output.options(model.Platform)
+	opts.Check = *check
+	opts.DryRun = *dryRun
+	opts.Stdout = *stdout
+
+	generateOutput(model, opts)
+}
+
+func runList(args []string) {
+	fs := newFlagSet("list")
+	in := addInputFlags(fs)
+	fs.Parse(args)
+
+	model := in.load()
+	entries := model.slotEntries()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NUMBER\tNAME\tARGS\tSTATUS\tGROUP")
+	for i, slot := range model.Slots {
+		args, group := "-", "-"
+		if entry := entries[i]; entry != nil {
+			args = entry.TotalArgs
+			group = entry.Group
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", slot.Number, slot.Name, args, slot.Status, group)
+	}
+	w.Flush()
+}
+
+func runShow(args []string) {
+	fs := newFlagSet("show")
+	in := addInputFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	model := in.load()
+	name := fs.Arg(0)
+	entry, ok := entriesByName(model)[name]
+	if ok != true {
+		for _, slot := range model.Slots {
+			if slot.Name == name {
+				log.Fatalf("Syscall %s is %s, it has no entry", name, slot.Status)
+			}
+		}
+		log.Fatalf("No syscall called %s in the %s master file", name, model.Platform.Display)
+	}
+
+	fmt.Printf("name:     %s\n", entry.SyscallName)
+	fmt.Printf("number:   %s\n", entry.EntryNumber)
+	fmt.Printf("platform: %s %s\n", model.Platform.Display, model.Platform.Arch)
+	fmt.Printf("returns:  %s\n", entry.ReturnType)
+	fmt.Printf("status:   %s\n", entry.Status)
+	fmt.Printf("group:    %s\n", entry.Group)
+	fmt.Printf("audit:    %s\n", orMissing(entry.AuditEvent))
+	if len(entry.Conditionals) > 0 {
+		fmt.Printf("if:       %s\n", strings.Join(entry.Conditionals, " && "))
+	}
+	fmt.Printf("args:     %s\n", entry.TotalArgs)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, arg := range entry.ArgArray {
+		fmt.Fprintf(w, "  %s\t%s\t%s\tkind=%s\tdir=%s\ttype=%s\tget=%s", arg.ArgSymbol, arg.CType, arg.ArgName,
+			arg.Kind, arg.Direction, orMissing(arg.ArgType), orMissing(arg.GetArg))
+		if arg.SyzType != "" {
+			fmt.Fprintf(w, "\tsyz=%s", arg.SyzType)
+		}
+		if values, ok := model.FlagSets[arg.Flags]; ok {
+			fmt.Fprintf(w, "\tflags=%s", strings.Join(values, "|"))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+func orMissing(str string) string {
+	if str == "" {
+		return "MISSING"
+	}
+	return str
+}
+
+func runExport(args []string) {
+	fs := newFlagSet("export")
+	in := addInputFlags(fs)
+	format := fs.String("format", "json", "The format to export, json, sql or sqlite. sql and sqlite take -os all.")
+	output := fs.String("o", "-", "The file to write to, - writes standard output.")
+	fs.Parse(args)
+
+	var data []byte
+	var err error
+	switch *format {
+	case "json":
+		data, err = exportModel(in.load())
+	case "sql":
+		data, err = exportSQL(in.loadAll())
+	case "sqlite":
+		data, err = exportSQLite(in.loadAll())
+	default:
+		log.Fatalf("Unknown export format %q, expected json, sql or sqlite", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *output == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+
+	if err := writeFileAtomic(*output, data); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadAll loads the model of every backend from its default master file
+// when -os is all, otherwise just the selected one.
+func (in *inputFlags) loadAll() []*Model {
+	if *in.os != "all" {
+		return []*Model{in.load()}
+	}
+
+	if *in.input != "" || *in.dialect != "" {
+		log.Fatal("-os all reads the default master file of every operating system, it can't be used with -input or -dialect")
+	}
+
+	var models []*Model
+	for _, backend := range backends {
+		name := backend.Name
+		in.os = &name
+		models = append(models, in.load())
+	}
+
+	return models
+}
+
+// entriesByName indexes the entries of the model by syscall name.
+func entriesByName(model *Model) map[string]Entry {
+	entries := make(map[string]Entry)
+	for _, entry := range model.Entries {
+		entries[entry.SyscallName] = entry
+	}
+
+	return entries
+}
+
+// validateModel returns a description of every problem in the model.
+func validateModel(model *Model) []string {
+	var problems []string
+
+	for _, entry := range model.Entries {
+		for i, arg := range entry.ArgArray {
+			if arg.ArgType == "" || arg.GetArg == "" {
+				problems = append(problems, fmt.Sprintf("%s: argument %d (%s) has no type mapping",
+					entry.SyscallName, i+1, strings.TrimSpace(arg.CType+" "+arg.ArgName)))
+			}
+		}
+	}
+
+	// A master file defines a syscall again under another class or
+	// conditions, ie COMPAT and STD, and the last definition wins. The
+	// same name or number twice under the same ones is a mistake.
+	seen := make(map[string]bool)
+	for _, slot := range model.Slots {
+		if slot.Status != statusOn {
+			continue
+		}
+
+		where := slot.Class + " " + strings.Join(slot.Conditionals, " && ")
+		name, number := "name "+slot.Name+" "+where, fmt.Sprint("number ", slot.Number, " ", where)
+		if seen[name] || seen[number] {
+			problems = append(problems, fmt.Sprintf("%s: defined more than once", slot.Name))
+		}
+		seen[name], seen[number] = true, true
+	}
+
+	return problems
+}
+
+func runValidate(args []string) {
+	fs := newFlagSet("validate")
+	in := addInputFlags(fs)
+	fs.Parse(args)
+
+	model := in.load()
+	problems := validateModel(model)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	// Missing descriptions are worth knowing about, but not a problem.
+	for _, name := range model.Undescribed {
+		fmt.Printf("warning: %s has no syzkaller description\n", name)
+	}
+
+	if len(problems) != 0 {
+		log.Fatalf("%d problems in the %s master file", len(problems), model.Platform.Display)
+	}
+}
+
+func runDiff(args []string) {
+	fs := newFlagSet("diff")
+	in := addInputFlags(fs)
+	output := addOutputFlags(fs)
+	fs.Parse(args)
+
+	model := in.load()
+	out := renderOutput(model, output.options(model.Platform))
+	changes, err := out.changes()
+	if err != nil {
+		log.Fatal("Can't compare output files: ", err)
+	}
+
+	out.diff(os.Stdout, changes)
+	if len(changes) != 0 {
+		os.Exit(1)
+	}
+}
+
+func runStats(args []string) {
+	fs := newFlagSet("stats")
+	in := addInputFlags(fs)
+	fs.Parse(args)
+
+	model := in.load()
+
+	statuses := make(map[string]int)
+	for _, slot := range model.Slots {
+		statuses[slot.Status]++
+	}
+
+	groups := make(map[string]int)
+	argCounts := make(map[string]int)
+	kinds := make(map[string]int)
+	for _, entry := range model.Entries {
+		groups[entry.Group]++
+		argCounts[entry.TotalArgs]++
+		for _, arg := range entry.ArgArray {
+			kinds[arg.Kind]++
+		}
+	}
+
+	fmt.Printf("platform:  %s %s\n", model.Platform.Display, model.Platform.Arch)
+	fmt.Printf("lines:     %d\n", len(model.Slots))
+	fmt.Printf("entries:   %d\n", len(model.Entries))
+	fmt.Printf("problems:  %d\n", len(validateModel(model)))
+	if *in.syz != "" {
+		fmt.Printf("described: %d\n", len(model.Entries)-len(model.Undescribed))
+	}
+	printCounts("status", statuses)
+	printCounts("group", groups)
+	printCounts("args", argCounts)
+	printCounts("arg kind", kinds)
+}
+
+// printCounts prints a count per key, sorted by key.
+func printCounts(title string, counts map[string]int) {
+	var keys []string
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("\n%s:\n", title)
+	for _, key := range keys {
+		fmt.Printf("  %-12s %d\n", key, counts[key])
+	}
+}
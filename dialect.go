@@ -0,0 +1,166 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Dialect describes the syntax of a kernel's syscalls.master file.
+type Dialect struct {
+	Name string
+
+	// OS is the operating system master files in this dialect are for.
+	OS string
+
+	// Normalize rewrites the master file into the one line per syscall
+	// form the parser understands.
+	Normalize func(config []byte) []byte
+}
+
+var dialects = []Dialect{
+	{Name: "freebsd", OS: "freebsd", Normalize: normalizeFreeBSD},
+	{Name: "xnu", OS: "darwin"},
+}
+
+// lookupDialect returns the dialect called name.
+func lookupDialect(name string) (Dialect, error) {
+	var names []string
+	for _, d := range dialects {
+		if d.Name == name {
+			return d, nil
+		}
+		names = append(names, d.Name)
+	}
+
+	return Dialect{}, fmt.Errorf("Unknown dialect %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// The default master files are compiled in next to the templates, so
+// entrygen finds them from any directory.
+//
+//go:embed input/*.master
+var defaultMasters embed.FS
+
+// readDefaultMaster reads the embedded master file called name.
+func readDefaultMaster(name string) ([]byte, error) {
+	return defaultMasters.ReadFile("input/" + name)
+}
+
+// readMaster reads a master file, a path of - reads standard input.
+func readMaster(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// parseMaster normalizes config for the dialect.
+func (d Dialect) parseMaster(config []byte) []byte {
+	if d.Normalize == nil {
+		return config
+	}
+
+	return d.Normalize(config)
+}
+
+// Annotations FreeBSD 13 and later put in front of arguments, ie
+// _In_reads_bytes_(nbyte) or _Contains_long_ptr_.
+var freebsdAnnotation = regexp.MustCompile(`\b_(In|Out|Inout|Contains)[A-Za-z_]*_(\([^()]*\))?\s*`)
+
+// joinContinuations joins every line ending in a backslash with the line
+// after it, the way older FreeBSD master files continue long syscalls:
+//
+//	3	AUE_READ	STD	{ ssize_t read(int fd, void *buf, \
+//					    size_t nbyte); }
+func joinContinuations(lines []string) []string {
+	var joined []string
+	continued := false
+
+	for _, line := range lines {
+		if continued {
+			last := len(joined) - 1
+			joined[last] = joined[last] + " " + strings.TrimSpace(line)
+		} else {
+			joined = append(joined, line)
+		}
+
+		last := len(joined) - 1
+		trimmed := strings.TrimRight(joined[last], " \t")
+		continued = strings.HasSuffix(trimmed, "\\")
+		if continued {
+			joined[last] = strings.TrimRight(strings.TrimSuffix(trimmed, "\\"), " \t")
+		}
+	}
+
+	return joined
+}
+
+// normalizeFreeBSD joins syscalls written over several lines into one
+// line. Lines ending in a backslash are joined with the next one, and so
+// is the syntax used since FreeBSD 13:
+//
+//	3	AUE_READ	STD|CAPENABLED {
+//		ssize_t read(
+//		    int fd,
+//		    _Out_writes_bytes_(nbyte) void *buf,
+//		    size_t nbyte
+//		);
+//	}
+//
+// Syscalls that fit on their first line are left alone.
+func normalizeFreeBSD(config []byte) []byte {
+	var buffer bytes.Buffer
+	var joined []string
+
+	for _, line := range joinContinuations(strings.Split(string(config), "\n")) {
+		if joined != nil {
+			trimmed := strings.TrimSpace(line)
+			joined = append(joined, freebsdAnnotation.ReplaceAllString(trimmed, ""))
+
+			if strings.HasPrefix(trimmed, "}") {
+				proto := strings.Join(joined, " ")
+				proto = strings.Replace(proto, "( ", "(", -1)
+				proto = strings.Replace(proto, " )", ")", -1)
+				buffer.WriteString(proto + "\n")
+				joined = nil
+			}
+			continue
+		}
+
+		trimmed := strings.TrimRight(line, " \t")
+		if len(trimmed) > 0 && trimmed[0] >= '0' && trimmed[0] <= '9' && strings.HasSuffix(trimmed, "{") {
+			joined = []string{trimmed}
+			continue
+		}
+
+		buffer.WriteString(line + "\n")
+	}
+
+	// A syscall that was never closed is kept as it is.
+	if joined != nil {
+		buffer.WriteString(strings.Join(joined, " ") + "\n")
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+}
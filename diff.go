@@ -0,0 +1,264 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// The number of unchanged lines shown around every change.
+const diffContext = 3
+
+// Give up on finding a minimal diff after this many edits and show the
+// rest as removed and added, otherwise huge rewrites take forever.
+const maxDiffEdits = 4000
+
+type diffOp struct {
+	Kind byte // One of ' ', '-' or '+'.
+	Line string
+}
+
+// splitLines splits data into lines without their newlines.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines returns the edit script turning a into b, found with
+// Myers' algorithm after stripping the common prefix and suffix.
+func diffLines(a []string, b []string) []diffOp {
+	var ops []diffOp
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{' ', a[prefix]})
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for i := len(a) - suffix; i < len(a); i++ {
+		ops = append(ops, diffOp{' ', a[i]})
+	}
+
+	return ops
+}
+
+func myers(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDiffEdits {
+		max = maxDiffEdits
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		// Remember the furthest reaching paths of this round so the
+		// edit script can be walked back afterwards. Only diagonals -d
+		// through d can be reached in d edits, so that band is enough.
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+
+	// Too many differences, replace everything.
+	var ops []diffOp
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+
+	return ops
+}
+
+// backtrack walks the furthest reaching paths back from the end, trace[d]
+// holds diagonals -d through d.
+func backtrack(a []string, b []string, trace [][]int, end int) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+
+	for d := end; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prev int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prev = k + 1
+		} else {
+			prev = k - 1
+		}
+
+		prevX := v[d+prev]
+		prevY := prevX - prev
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+
+	// The ops were collected from the end.
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// unifiedDiff returns the difference between the old and new content in
+// the unified diff format, or an empty string if they are the same.
+func unifiedDiff(oldName string, newName string, old []byte, new []byte) string {
+	ops := diffLines(splitLines(old), splitLines(new))
+
+	var buf bytes.Buffer
+	for i := 0; i < len(ops); {
+		// Find the next change.
+		for i < len(ops) && ops[i].Kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// Extend the hunk until there are more than two context
+		// worth of unchanged lines in a row.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		writeHunk(&buf, ops, start, end)
+		i = end
+	}
+
+	return buf.String()
+}
+
+func writeHunk(buf *bytes.Buffer, ops []diffOp, start int, end int) {
+	// Count the lines of each side before and inside of the hunk.
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.Kind != '+' {
+			oldLine++
+		}
+		if op.Kind != '-' {
+			newLine++
+		}
+	}
+
+	oldCount, newCount := 0, 0
+	for _, op := range ops[start:end] {
+		if op.Kind != '+' {
+			oldCount++
+		}
+		if op.Kind != '-' {
+			newCount++
+		}
+	}
+
+	// An empty side of a hunk starts at the line before it.
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+
+	fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	for _, op := range ops[start:end] {
+		buf.WriteByte(op.Kind)
+		buf.WriteString(op.Line)
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,211 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Docs is the data for the syscall reference templates.
+type Docs struct {
+	Platform   Platform
+	Calls      []DocCall
+	On         int
+	Superseded int
+	Nosys      int
+	Obsolete   int
+	Filtered   int
+	Described  int
+}
+
+// DocCall is the reference of one numbered line of the master file.
+type DocCall struct {
+	Number       int
+	Name         string
+	Anchor       string // The id the summary links to, ie sys-3.
+	Status       string
+	Reason       string // Why a syscall that isn't on, or is superseded, has no entry.
+	Prototype    string
+	Group        string
+	Class        string // The type column of the master file, ie STD.
+	Compat       string // The kernel option a compat syscall needs, ie COMPAT_FREEBSD4.
+	AuditEvent   string
+	Conditionals []string
+	Described    bool
+	Args         []DocArg
+}
+
+// DocArg is one argument and how nextgen generates it.
+type DocArg struct {
+	Name      string
+	CType     string
+	Kind      string
+	Direction string
+	ArgType   string
+	Generator string // The nextgen function that generates the argument, ie generate_fd.
+}
+
+// compatOption returns the FreeBSD kernel option a COMPAT class is built
+// under, COMPAT is COMPAT_43 and COMPATn is COMPAT_FREEBSDn.
+func compatOption(class string) string {
+	for _, part := range strings.Split(class, "|") {
+		switch {
+		case part == "COMPAT":
+			return "COMPAT_43"
+		case strings.HasPrefix(part, "COMPAT"):
+			if _, err := strconv.Atoi(part[len("COMPAT"):]); err == nil {
+				return "COMPAT_FREEBSD" + part[len("COMPAT"):]
+			}
+		}
+	}
+
+	return ""
+}
+
+// docReason explains why a slot has no entry.
+func docReason(slot Slot) string {
+	switch slot.Status {
+	case statusNosys:
+		if slot.Comment != "" {
+			return "nosys placeholder, " + slot.Comment
+		}
+		return "nosys placeholder"
+	case statusObsolete:
+		switch {
+		case slot.Comment != "":
+			return strings.TrimSpace(slot.Class + " " + slot.Comment)
+		case slot.Class == "OBSOL" || slot.Class == "UNIMPL" || slot.Class == "RESERVED":
+			return slot.Class
+		}
+		return "no prototype"
+	case statusFiltered:
+		return "left out by the -only, -exclude, -range or -group filters"
+	}
+
+	return ""
+}
+
+// docPrototype writes the C prototype of an entry back out.
+func docPrototype(entry Entry) string {
+	var params []string
+	for _, arg := range entry.ArgArray {
+		if strings.HasSuffix(arg.CType, "*") {
+			params = append(params, arg.CType+arg.ArgName)
+			continue
+		}
+		params = append(params, arg.CType+" "+arg.ArgName)
+	}
+
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+
+	return fmt.Sprintf("%s %s(%s)", entry.ReturnType, strings.TrimSpace(entry.SyscallName), strings.Join(params, ", "))
+}
+
+// A syscall that is on but redefined later, its entry is left out like in
+// the C output.
+const docSuperseded = "superseded"
+
+// buildDocs converts every slot of the model to its reference.
+func buildDocs(model *Model) Docs {
+	d := Docs{Platform: model.Platform}
+
+	entries := model.slotEntries()
+	redefined := model.redefinitions()
+	next := 0
+	for i, slot := range model.Slots {
+		c := DocCall{Number: slot.Number,
+			Name:         strings.TrimSpace(slot.Name),
+			Anchor:       "sys-" + strconv.Itoa(slot.Number),
+			Status:       slot.Status,
+			Reason:       docReason(slot),
+			Class:        slot.Class,
+			Compat:       compatOption(slot.Class),
+			AuditEvent:   slot.AuditEvent,
+			Conditionals: slot.Conditionals}
+
+		switch slot.Status {
+		case statusNosys:
+			d.Nosys++
+		case statusObsolete:
+			d.Obsolete++
+		case statusFiltered:
+			d.Filtered++
+		}
+
+		entry := entries[i]
+		if entry == nil {
+			d.Calls = append(d.Calls, c)
+			continue
+		}
+
+		c.Prototype = docPrototype(*entry)
+		c.Group = entry.Group
+		c.Described = entry.Described
+
+		reason := redefined[next]
+		next++
+		if reason != "" {
+			c.Status = docSuperseded
+			c.Reason = reason
+			d.Superseded++
+			d.Calls = append(d.Calls, c)
+			continue
+		}
+
+		d.On++
+		if entry.Described {
+			d.Described++
+		}
+
+		for _, arg := range entry.ArgArray {
+			c.Args = append(c.Args, DocArg{Name: arg.ArgName,
+				CType:     arg.CType,
+				Kind:      arg.Kind,
+				Direction: arg.Direction,
+				ArgType:   arg.ArgType,
+				Generator: strings.TrimPrefix(arg.GetArg, "&")})
+		}
+
+		d.Calls = append(d.Calls, c)
+	}
+
+	return d
+}
+
+// renderDocs generates a Markdown and an HTML reference of every syscall.
+func renderDocs(out *Output, model *Model, opts Options) {
+	d := buildDocs(model)
+
+	files := []struct{ name, template string }{
+		{"syscalls_" + model.Platform.Name + ".md", "docs.txt"},
+		{"syscalls_" + model.Platform.Name + ".html", "docs_html.txt"}}
+
+	for _, f := range files {
+		t, err := loadTemplate(opts.TemplateDir, model, f.template)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = out.execute(f.name, t, d); err != nil {
+			log.Fatal("Can't write reference file: ", err)
+		}
+	}
+}
@@ -17,44 +17,256 @@ package main
 
 import (
 	"bytes"
-	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
-	"text/template"
 	"time"
 )
 
+// Platform identifies the operating system and architecture that the
+// output files are generated for.
+type Platform struct {
+	Name    string // The name passed to -os, ie freebsd or darwin.
+	Display string // Human readable name, ie FreeBSD or macOS.
+	Arch    string // The architecture, ie amd64 or arm64.
+	Ident   string // C identifier prefix for generated symbols, ie freebsd_amd64.
+	Guard   string // Include guard macro for the table header.
+}
+
 type Syscalls struct {
+	Platform      Platform
 	Syscall       []SyscallName
 	Year          string
 	TotalSyscalls int
+	ByNumber      []SyscallName // Sorted by syscall number, used for the number indexed table.
+	TotalByNumber int           // Highest syscall number plus one, holes included.
+	ByName        []SyscallName // Sorted by name, used for binary search.
+	TotalByName   int
 }
 
 type SyscallName struct {
 	Name    string
 	Counter int
+	Number  int // The syscall number from the master file.
 }
 
 type Arg struct {
 	ArgType   string
 	GetArg    string
 	ArgSymbol string
+	CType     string // The C type from the prototype, ie const char *.
+	ArgName   string // The parameter name from the prototype.
+	Kind      string // What the argument holds, ie pointer, pid, port or int.
+	Direction string // Which way data moves through the argument, in or inout.
+	SyzType   string // The type from a syzkaller description, if there is one.
+	Flags     string // The flag set the argument takes, from a syzkaller description.
+	LenOf     string // The argument this one is the length of, from a syzkaller description.
 }
 
 type Entry struct {
-	Year        string // The year the output files were generated. Used for copyright.
-	SyscallName string // The name of the system call, ie read, write, wait4, etc.
-	Status      string // Whether the syscall is on or off, defaults to on.
-	TotalArgs   string
-	EntryNumber string
-	ReturnType  string
-	ArgArray    []Arg
-	TypeArray   []Arg
+	Year          string // The year the output files were generated. Used for copyright.
+	SyscallName   string // The name of the system call, ie read, write, wait4, etc.
+	Status        string // Whether the syscall is on or off, defaults to on.
+	TotalArgs     string
+	EntryNumber   string
+	ReturnType    string
+	Group         string   // The category of the syscall, ie net, file or process.
+	AuditEvent    string   // The audit event of the syscall, ie AUE_READ.
+	Conditionals  []string // The preprocessor conditions the syscall is under, ie SOCKETS.
+	Described     bool     // Whether a syzkaller description enriched the arguments.
+	SyscallMacros bool     // Use SYS_<name> from <sys/syscall.h> instead of EntryNumber.
+	ArgArray      []Arg
+	TypeArray     []Arg
+}
+
+// newPlatform creates the platform identity used to name the generated
+// tables and include guards.
+func newPlatform(name string, arch string) Platform {
+	display := name
+	if backend, err := lookupBackend(name); err == nil {
+		display = backend.Display
+	}
+
+	ident := cIdentifier(name + "_" + arch)
+
+	return Platform{Name: name,
+		Display: display,
+		Arch:    arch,
+		Ident:   ident,
+		Guard:   strings.ToUpper(ident) + "_SYSCALL_TABLE_H"}
+}
+
+// cIdentifier replaces every character that is not valid in a C identifier
+// with an underscore.
+func cIdentifier(str string) string {
+	var buffer bytes.Buffer
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			buffer.WriteByte(c)
+			continue
+		}
+		buffer.WriteByte('_')
+	}
+
+	return buffer.String()
+}
+
+// The status of a numbered line in the master file.
+const (
+	statusOn       = "on"       // A syscall that gets an entry.
+	statusNosys    = "nosys"    // A nosys or enosys placeholder.
+	statusObsolete = "obsolete" // A line without a prototype, like OBSOL or UNIMPL.
+	statusFiltered = "filtered" // A syscall left out by the -only, -exclude, -range or -group filters.
+)
+
+// Slot is one numbered line of the master file, holes included.
+type Slot struct {
+	Number       int
+	Name         string
+	Status       string
+	AuditEvent   string   // The audit event of the syscall, ie AUE_READ.
+	Conditionals []string // The preprocessor conditions the syscall is under.
+	Class        string   // The type column of the master file, ie STD or COMPAT4.
+	Comment      string   // The comment of a line that isn't a syscall, ie old creat.
+}
+
+// Model is everything entrygen parsed out of a syscall master file
+// for one platform. Templates can reach it with the model function.
+type Model struct {
+	Platform Platform
+	Slots    []Slot  // Every numbered line in the order of the master file.
+	Entries  []Entry // The syscalls that get an entry.
+
+	// FlagSets are the values of the flag sets arguments take, by name.
+	FlagSets map[string][]string
+
+	// Undescribed are the entries syzkaller descriptions were given for
+	// but didn't describe.
+	Undescribed []string
+}
+
+// redefinitions returns why each entry is left out of output that defines
+// a syscall once, or "" for the entries that are kept. A master file can
+// define a syscall twice, ie stat under COMPAT and STD, and like the file
+// of a per-file entry being replaced the last definition wins.
+func (m *Model) redefinitions() []string {
+	reasons := make([]string, len(m.Entries))
+	names := make(map[string]int)
+	numbers := make(map[string]int)
+
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		entry := m.Entries[i]
+		name := strings.TrimSpace(entry.SyscallName)
+
+		last, ok := names[name]
+		if ok != true {
+			last, ok = numbers[entry.EntryNumber]
+		}
+
+		if ok {
+			kept := m.Entries[last]
+			reasons[i] = fmt.Sprintf("%s %s is skipped, %s %s defines it again", name, entry.EntryNumber,
+				strings.TrimSpace(kept.SyscallName), kept.EntryNumber)
+			continue
+		}
+
+		names[name] = i
+		numbers[entry.EntryNumber] = i
+	}
+
+	return reasons
+}
+
+// Skip says why an output leaves a syscall out, the templates write it as
+// a comment in its place.
+type Skip struct {
+	Skipped string
+}
+
+// skips returns why each entry is left out of an output that defines a
+// syscall once and supports at most maxArgs arguments, or "" for the
+// entries that are kept.
+func (m *Model) skips(maxArgs int) []string {
+	reasons := m.redefinitions()
+	for i, entry := range m.Entries {
+		if reasons[i] == "" && len(entry.ArgArray) > maxArgs {
+			reasons[i] = fmt.Sprintf("%s is skipped, it has %d arguments and at most %d are supported",
+				strings.TrimSpace(entry.SyscallName), len(entry.ArgArray), maxArgs)
+		}
+	}
+
+	return reasons
+}
+
+// slotEntries returns the entry of every slot, or nil for the slots that
+// aren't on. Entries are in the same order as the slots that are on.
+func (m *Model) slotEntries() []*Entry {
+	entries := make([]*Entry, len(m.Slots))
+
+	next := 0
+	for i, slot := range m.Slots {
+		if slot.Status == statusOn {
+			entries[i] = &m.Entries[next]
+			next++
+		}
+	}
+
+	return entries
+}
+
+// definedSlots returns the slots of definedEntries, the ones that are on
+// without the ones redefined later.
+func (m *Model) definedSlots() []Slot {
+	reasons := m.redefinitions()
+	var slots []Slot
+
+	next := 0
+	for _, slot := range m.Slots {
+		if slot.Status != statusOn {
+			continue
+		}
+		if reasons[next] == "" {
+			slots = append(slots, slot)
+		}
+		next++
+	}
+
+	return slots
+}
+
+// definedEntries returns the entries without the ones redefined later.
+func (m *Model) definedEntries() []Entry {
+	var entries []Entry
+	for i, reason := range m.redefinitions() {
+		if reason == "" {
+			entries = append(entries, m.Entries[i])
+		}
+	}
+
+	return entries
+}
+
+// Options controls how the output files are generated.
+type Options struct {
+	Format        string // The output format, ie c or syzlang.
+	GoPackage     string // The package name of the go format.
+	SyscallMacros bool   // Emit SYS_* constants instead of raw syscall numbers.
+	OutputDir     string // Where to write the output files, defaults to the platform name.
+	EntryPattern  string // File name of each entry file, {{name}} is the syscall name.
+	TemplateDir   string // Directory with templates that override the embedded defaults.
+	Amalgamate    bool   // Write all entries and tables into syscalls_<os>.c and .h.
+	Shards        int    // Number of .c files to spread the entries over when amalgamating.
+	Year          string // Pinned copyright year, empty to keep the year in existing files.
+	Check         bool   // Compare the output with the files on disk instead of writing it.
+	DryRun        bool   // Print the files that would change instead of writing them.
+	Stdout        string // Print this syscall, table or list to standard output instead of writing it.
 }
 
 func extractReturnType(proto string) string {
@@ -567,8 +779,9 @@ func generateGetType(str []string) []string {
 	return funcArray
 }
 
-func generateGetArgArray(proto string, count string) []string {
-	// var variables int
+// splitParams returns the parameters of a syscall prototype, each one
+// still containing both the type and the name.
+func splitParams(proto string, count string) []string {
 	reg := regexp.MustCompilePOSIX("\\((.*?)\\)")
 	params := reg.Find([]byte(proto))
 
@@ -603,49 +816,24 @@ func generateGetArgArray(proto string, count string) []string {
 		}
 	}
 
-	// Take the translated types and generate the right get arg function name.
-	return generateGetArgFunction(str)
+	return str[:totalArgs]
 }
 
-func generateGetTypeArray(proto string, count string) []string {
-	reg := regexp.MustCompilePOSIX("\\((.*?)\\)")
-	params := reg.Find([]byte(proto))
-
-	// Check if there are no arguments, if yes return zero.
-	if string(params) == "(void)" {
-		return nil
-	}
-
-	// Convert string to a number.
-	totalArgs, err := strconv.Atoi(count)
-	if err != nil {
-		log.Fatal("Can't convert string")
-		return nil
-	}
-
-	// Declare some variables and make a string slice.
-	var str = make([]string, totalArgs+1)
-	var buffer bytes.Buffer
-	commaCount := 0
-
-	// Parse out arguments types and stick them into the string slice, str.
-	for i := 1; i < len(params); i++ {
-		if string(params[i]) == ")" {
-			str[commaCount] = buffer.String()
-			break
-		} else if string(params[i]) == "," {
-			str[commaCount] = buffer.String()
-			buffer.Reset()
-			commaCount++
-		} else {
-			buffer.WriteString(string(params[i]))
-		}
-	}
-
-	return generateGetType(str)
-}
-
-func createArgArray(types []string, args []string, count string) []Arg {
+// argSymbols are the names of the argument slots in the generated entries.
+var argSymbols = [...]string{"FIRST_ARG",
+	"SECOND_ARG",
+	"THIRD_ARG",
+	"FOURTH_ARG",
+	"FIFTH_ARG",
+	"SIXTH_ARG",
+	"SEVENTH_ARG",
+	"EIGTH_ARG",
+	"NINTH_ARG",
+	"TENTH_ARG",
+	"ELEVENTH_ARG",
+	"TWELFTH_ARG"}
+
+func createArgArray(params []string, types []string, args []string, count string) []Arg {
 	// Convert string to a number.
 	totalArgs, err := strconv.Atoi(count)
 	if err != nil {
@@ -654,23 +842,13 @@ func createArgArray(types []string, args []string, count string) []Arg {
 
 	argArray := make([]Arg, totalArgs)
 
-	symbolArray := [...]string{"FIRST_ARG",
-		"SECOND_ARG",
-		"THIRD_ARG",
-		"FOURTH_ARG",
-		"FIFTH_ARG",
-		"SIXTH_ARG",
-		"SEVENTH_ARG",
-		"EIGTH_ARG",
-		"NINTH_ARG",
-		"TENTH_ARG",
-		"ELEVENTH_ARG",
-		"TWELFTH_ARG"}
-
 	for i := 0; i < totalArgs; i++ {
 		argArray[i].GetArg = args[i]
-		argArray[i].ArgSymbol = symbolArray[i]
+		argArray[i].ArgSymbol = argSymbols[i]
 		argArray[i].ArgType = types[i]
+		argArray[i].CType, argArray[i].ArgName = splitArgName(params[i])
+		argArray[i].Kind = argKind(argArray[i].CType)
+		argArray[i].Direction = argDirection(argArray[i].CType)
 	}
 
 	return argArray
@@ -687,7 +865,7 @@ func checkEntry(entry Entry) bool {
 	return true
 }
 
-func writeEntry(entry Entry, name string, dir string) {
+func writeEntry(out *Output, entry Entry, name string, model *Model, opts Options) {
 	if len(name) == 0 {
 		return
 	}
@@ -697,32 +875,24 @@ func writeEntry(entry Entry, name string, dir string) {
 	}
 
 	// Create a syscall entry template.
-	t := template.New("input/entry.txt")
-	t, err := template.ParseFiles("input/entry.txt", "input/warning.txt", "input/copyright.txt")
+	t, err := loadTemplate(opts.TemplateDir, model, "entry.txt")
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	// Create a syscall entry file with the system call name appened to entry_.
-	f, err := os.Create(dir + "/entry_" + name + ".c")
-	if err != nil {
-		log.Fatal("Can't create file: ", err)
-		return
-	}
-
-	// Close the file when it goes out of scope.
-	defer f.Close()
+	// Create a syscall entry file named after the system call.
+	file := entryFileName(opts.EntryPattern, name)
+	entry.Year = copyrightYear(out.path(file), opts)
 
-	// Write the template to disk.
-	err = t.Execute(f, entry)
+	err = out.execute(file, t, entry)
 	if err != nil {
 		log.Fatal("Can't write entry file: ", err)
 		return
 	}
 }
 
-func createEntryObject(syscall string) Entry {
+func createEntryObject(syscall string, mappings map[string]TypeMapping) Entry {
 	// Extract the syscall function prototype.
 	proto := extractFunctionPrototype(syscall)
 
@@ -745,35 +915,128 @@ func createEntryObject(syscall string) Entry {
 	// Figure out what type the syscall returns.
 	returnType := extractReturnType(proto)
 
-	// Generate the get argument array.
-	args := generateGetArgArray(proto, count)
+	// Split the prototype into it's parameters.
+	params := splitParams(proto, count)
 
-	// Generate get type array.
-	types := generateGetTypeArray(proto, count)
+	// Generate the get type and get argument arrays.
+	types, args := mapTypes(params, mappings)
 
-	// Get the current year.
-	now := time.Now()
-	year := strconv.Itoa(now.Year())
-
-	argArray := createArgArray(types, args, count)
+	argArray := createArgArray(params, types, args, count)
 
 	e = Entry{EntryNumber: syscallNumber,
 		TotalArgs:   count,
-		Year:        year,
 		ReturnType:  returnType,
 		SyscallName: syscallName,
+		Status:      statusOn,
+		Group:       syscallGroup(syscallName),
 		ArgArray:    argArray}
 
 	return e
 }
 
-func createEntry(syscall string, basedir string) {
-	// Extract information from string and create a
-	// syscall entry object with the information.
-	entry := createEntryObject(syscall)
+// stdoutFile returns the generated file -stdout asks for. It can be the
+// name of a syscall, table, list, or the name of any generated file.
+func stdoutFile(out *Output, model *Model, opts Options) (string, error) {
+	if _, ok := out.Files[opts.Stdout]; ok {
+		return opts.Stdout, nil
+	}
+
+	var name string
+	switch opts.Stdout {
+	case "table":
+		name = model.Platform.Name + "_table.h"
+		if opts.Amalgamate {
+			name = amalgamationName(model.Platform, ".c")
+		}
+	case "list":
+		name = "syscall_list.h"
+		if opts.Amalgamate {
+			name = amalgamationName(model.Platform, ".h")
+		}
+	default:
+		if opts.Amalgamate {
+			return "", fmt.Errorf("can't print syscall %s on it's own with -single", opts.Stdout)
+		}
+		name = entryFileName(opts.EntryPattern, opts.Stdout)
+	}
 
-	// Write the syscall entry to disk.
-	writeEntry(entry, entry.SyscallName, basedir)
+	if _, ok := out.Files[name]; ok != true {
+		return "", fmt.Errorf("no generated output for %s", opts.Stdout)
+	}
+
+	return name, nil
+}
+
+// copyrightYear returns the year to put in the copyright notice of file.
+// A pinned year wins, otherwise the year already in the file is kept so
+// regenerating doesn't change files just because the calendar did.
+func copyrightYear(file string, opts Options) string {
+	if opts.Year != "" {
+		return opts.Year
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if err == nil {
+		reg := regexp.MustCompile(`Copyright \(c\) ([0-9]{4})`)
+		match := reg.FindSubmatch(buf)
+		if match != nil {
+			return string(match[1])
+		}
+	}
+
+	return strconv.Itoa(time.Now().Year())
+}
+
+// pinnedYear returns the copyright year from the -year flag, or from
+// SOURCE_DATE_EPOCH when the flag is empty. An empty string means
+// the year isn't pinned.
+func pinnedYear(year string) (string, error) {
+	if year != "" {
+		if _, err := strconv.Atoi(year); err != nil || len(year) != 4 {
+			return "", fmt.Errorf("invalid year %q", year)
+		}
+		return year, nil
+	}
+
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return "", nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", epoch)
+	}
+
+	return strconv.Itoa(time.Unix(seconds, 0).UTC().Year()), nil
+}
+
+// entryFileName expands the {{name}} placeholder in an entry file name pattern.
+func entryFileName(pattern string, name string) string {
+	return strings.Replace(pattern, "{{name}}", name, -1)
+}
+
+// nextgenDir returns where the output files for a platform live inside
+// of a nextgen checkout.
+func nextgenDir(root string, platform Platform) string {
+	return filepath.Join(root, "src", "syscall", platform.Name)
+}
+
+// createOutputDir creates the output directory and any missing parents.
+func createOutputDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil {
+		if info.IsDir() != true {
+			return fmt.Errorf("%s exists and is not a directory", dir)
+		}
+		return nil
+	}
+
+	if os.IsNotExist(err) != true {
+		return err
+	}
+
+	return os.MkdirAll(dir, 0755)
 }
 
 func extractFunctionPrototype(syscall string) string {
@@ -803,18 +1066,16 @@ func extractTotalArgs(proto string) string {
 	reg := regexp.MustCompilePOSIX("\\((.*?)\\)")
 	params := reg.Find([]byte(proto))
 
-	if strings.Contains(proto, ",") != true {
+	// Check if there are no arguments, if yes return zero. A parameter
+	// list without a comma still has one argument, ie close(int fd).
+	inside := strings.TrimSpace(strings.Trim(string(params), "()"))
+	if inside == "" || inside == "void" {
 		return "0"
 	}
 
 	// Count represents how many parenthesis have been found. Default to one.
 	var count int = 1
 
-	// Check if there are no arguments, if yes return zero.
-	if string(params) == "(void)" {
-		return string(0)
-	}
-
 	// Count the parenthesis and use that as the amount of args.
 	for i := 0; i < len(params); i++ {
 		if string(params[i]) == "(" {
@@ -867,189 +1128,358 @@ func getSyscallName(syscall string) string {
 	return syscallName
 }
 
-func createSyscallList(syscalls []string, dir string) {
-	t := template.New("input/syscall_list.txt")
-	t, err := template.ParseFiles("input/syscall_list.txt", "input/warning.txt", "input/copyright.txt")
+func createSyscallList(out *Output, file string, model *Model, opts Options) {
+	t, err := loadTemplate(opts.TemplateDir, model, "syscall_list.txt")
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	f, err := os.Create(dir + "/syscall_list.h")
+	year := copyrightYear(out.path(file), opts)
+
+	var names []SyscallName
+
+	for _, slot := range model.Slots {
+		// Skip empty syscall entries.
+		if slot.Status != statusOn {
+			continue
+		}
+		name := SyscallName{Name: "entry_" + slot.Name}
+		names = append(names, name)
+	}
+
+	s := Syscalls{Platform: model.Platform, Syscall: names, Year: year}
+
+	err = out.execute(file, t, s)
 	if err != nil {
-		log.Fatal("Can't create file: ", err)
+		log.Fatal("Can't write entry file: ", err)
 		return
 	}
+}
 
-	// Close the file when it goes out of scope.
-	defer f.Close()
-
-	now := time.Now()
-	year := strconv.Itoa(now.Year())
+// isHole reports whether a syscall name is a placeholder that does not
+// get an entry, such as nosys, enosys or an obsolete syscall without a prototype.
+func isHole(name string) bool {
+	return name == "" || name == "enosys" || name == "nosys"
+}
 
+// buildSyscallTables creates the dense table, the number indexed table and
+// the name sorted table from the numbered lines of the master file.
+func buildSyscallTables(slots []Slot) Syscalls {
 	var names []SyscallName
+	var byNumber []SyscallName
+	totalByNumber := 0
 
-	for i := 0; i < len(syscalls); i++ {
+	for _, slot := range slots {
 		// Skip empty syscall entries.
-		if syscalls[i] == "enosys" || syscalls[i] == "nosys" {
+		if slot.Status != statusOn {
 			continue
 		}
-		name := SyscallName{Name: "entry_" + syscalls[i]}
+		name := SyscallName{Name: "entry_" + slot.Name, Counter: len(names), Number: slot.Number}
 		names = append(names, name)
+		byNumber = append(byNumber, name)
+		if slot.Number+1 > totalByNumber {
+			totalByNumber = slot.Number + 1
+		}
 	}
 
-	s := Syscalls{Syscall: names, Year: year}
+	sort.Slice(byNumber, func(i, j int) bool { return byNumber[i].Number < byNumber[j].Number })
 
-	// Write the template to disk.
-	err = t.Execute(f, s)
-	if err != nil {
-		log.Fatal("Can't write entry file: ", err)
-		return
+	byName := make([]SyscallName, len(names))
+	copy(byName, names)
+	sort.SliceStable(byName, func(i, j int) bool { return byName[i].Name < byName[j].Name })
+	for i := range byName {
+		byName[i].Counter = i
 	}
+
+	return Syscalls{Syscall: names,
+		TotalSyscalls: len(names),
+		ByNumber:      byNumber,
+		TotalByNumber: totalByNumber,
+		ByName:        byName,
+		TotalByName:   len(byName)}
 }
 
-func createSyscallTables(syscalls []string, dir string) {
-	t := template.New("input/syscall_table.txt")
-	t, err := template.ParseFiles("input/syscall_table.txt", "input/warning.txt", "input/copyright.txt")
+func createSyscallTables(out *Output, model *Model, opts Options) {
+	t, err := loadTemplate(opts.TemplateDir, model, "syscall_table.txt")
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	f, err := os.Create(dir + "/" + dir + "_table.h")
+	file := model.Platform.Name + "_table.h"
+	year := copyrightYear(out.path(file), opts)
+
+	s := buildSyscallTables(model.definedSlots())
+	s.Platform = model.Platform
+	s.Year = year
+
+	err = out.execute(file, t, s)
 	if err != nil {
-		log.Fatal("Can't create file: ", err)
+		log.Fatal("Can't write entry file: ", err)
 		return
 	}
+}
 
-	// Close the file when it goes out of scope.
-	defer f.Close()
+// parseModel parses every numbered line of a syscall master file. Syscalls
+// the filter rejects become holes so the rest keep their numbers.
+func parseModel(platform Platform, config []byte, filter Filter) *Model {
+	model := &Model{Platform: platform}
 
-	now := time.Now()
-	year := strconv.Itoa(now.Year())
+	backend, _ := lookupBackend(platform.Name)
 
-	var names []SyscallName
-	counter := 0
-	var i int
+	// The preprocessor conditions the current line is under.
+	var conditionals []string
 
-	for i = 0; i < len(syscalls); i++ {
-		// Skip empty syscall entries.
-		if syscalls[i] == "enosys" || syscalls[i] == "nosys" {
-			counter--
+	// Loop over the syscall function prototypes and syscall numbers and
+	// create syscall entries for this platform.
+	for _, s := range strings.Split(string(config), "\n") {
+		if strings.HasPrefix(s, "#") {
+			conditionals = updateConditionals(conditionals, s)
 			continue
 		}
-		name := SyscallName{Name: "entry_" + syscalls[i], Counter: i + counter}
-		names = append(names, name)
+
+		if len(s) == 0 || s[0] < '0' || s[0] > '9' {
+			continue
+		}
+
+		name := getSyscallName(s)
+		number, _ := strconv.Atoi(extractSyscallNumber(s))
+		slot := Slot{Number: number,
+			Name:         name,
+			Status:       statusOn,
+			AuditEvent:   extractAuditEvent(s),
+			Conditionals: append([]string(nil), conditionals...),
+			Class:        extractClass(s)}
+
+		switch {
+		case name == "":
+			slot.Status = statusObsolete
+		case isHole(name):
+			slot.Status = statusNosys
+		case filter.match(name, number, syscallGroup(name)) != true:
+			slot.Status = statusFiltered
+		}
+
+		if slot.Status == statusNosys || slot.Status == statusObsolete {
+			slot.Comment = extractComment(s)
+		}
+
+		model.Slots = append(model.Slots, slot)
+		if slot.Status != statusOn {
+			continue
+		}
+
+		// Extract information from string and create a
+		// syscall entry object with the information.
+		entry := createEntryObject(s, backend.Types)
+		entry.AuditEvent = slot.AuditEvent
+		entry.Conditionals = slot.Conditionals
+		model.Entries = append(model.Entries, entry)
 	}
 
-	s := Syscalls{Syscall: names, Year: year, TotalSyscalls: i + counter}
+	return model
+}
 
-	// Write the template to disk.
-	err = t.Execute(f, s)
-	if err != nil {
-		log.Fatal("Can't write entry file: ", err)
-		return
+// updateConditionals tracks #if, #else and #endif lines so every syscall
+// knows the conditions it's compiled under. Inside #else the condition
+// is negated, ie !SOCKETS.
+func updateConditionals(conditionals []string, line string) []string {
+	fields := strings.Fields(line)
+	directive := strings.TrimPrefix(fields[0], "#")
+	cond := ""
+	if len(fields) > 1 {
+		cond = strings.Join(fields[1:], " ")
+	}
+
+	switch directive {
+	case "if":
+		return append(conditionals, cond)
+	case "ifdef":
+		return append(conditionals, "defined("+cond+")")
+	case "ifndef":
+		return append(conditionals, "!defined("+cond+")")
+	case "elif":
+		if len(conditionals) > 0 {
+			conditionals = append(conditionals[:len(conditionals)-1:len(conditionals)-1], cond)
+		}
+	case "else":
+		if len(conditionals) > 0 {
+			last := conditionals[len(conditionals)-1]
+			conditionals = append(conditionals[:len(conditionals)-1:len(conditionals)-1], negateConditional(last))
+		}
+	case "endif":
+		if len(conditionals) > 0 {
+			conditionals = conditionals[:len(conditionals)-1]
+		}
+	}
+
+	return conditionals
+}
+
+func negateConditional(cond string) string {
+	if strings.ContainsAny(cond, " &|") {
+		return "!(" + cond + ")"
 	}
+
+	return "!" + cond
 }
 
-func generateOutput(platform string, config []byte) {
-	// Extract all the syscall function prototypes and syscall numbers.
-	reg := regexp.MustCompilePOSIX("(^[0-9]+).*?")
-	syscalls := reg.FindAll(config, len(config))
+// extractAuditEvent returns the audit event of a syscall line, ie AUE_READ.
+func extractAuditEvent(syscall string) string {
+	fields := strings.Fields(syscall)
+	if len(fields) > 1 && strings.HasPrefix(fields[1], "AUE_") {
+		return fields[1]
+	}
+
+	return ""
+}
 
-	// Check if a platform folder has been created, if not
-	// create one using the name of the os.
-	if _, err := os.Stat(platform); os.IsNotExist(err) {
-		os.Mkdir(platform, 0777)
+// extractClass returns the type column of a master file line, ie STD,
+// COMPAT4 or NOSTD|NOTSTATIC on FreeBSD and ALL on XNU.
+func extractClass(syscall string) string {
+	fields := strings.Fields(syscall)
+	if len(fields) > 2 && strings.HasPrefix(fields[1], "AUE_") {
+		return fields[2]
 	}
 
-	var names []string
+	return ""
+}
 
-	// Loop and create syscall entries for this platform.
-	for i := 0; i < len(syscalls); i++ {
-		s := string(syscalls[i])
-		createEntry(s, platform)
-		namesArray := names
-		names = make([]string, i+1)
-		copy(names, namesArray)
-		name := getSyscallName(s)
-		names[i] = name
+// extractComment returns what a master file line says about a syscall
+// that isn't there. That's the name after OBSOL or UNIMPL on FreeBSD and
+// the braced comment after the prototype on XNU, ie old creat.
+func extractComment(syscall string) string {
+	fields := strings.Fields(syscall)
+	if len(fields) < 4 || strings.HasPrefix(fields[1], "AUE_") != true {
+		return ""
 	}
 
-	createSyscallList(names, platform)
-	createSyscallTables(names, platform)
+	rest := strings.Join(fields[3:], " ")
+	if strings.HasPrefix(rest, "{") != true {
+		return rest
+	}
 
-	return
+	end := strings.Index(rest, "}")
+	if end == -1 {
+		return ""
+	}
+
+	rest = strings.TrimSpace(rest[end+1:])
+	if strings.HasPrefix(rest, "{") && strings.HasSuffix(rest, "}") {
+		return strings.TrimSpace(rest[1 : len(rest)-1])
+	}
+
+	return ""
 }
 
-func defaultBuild() {
-	var err error
-	var SyscallListBuf []byte
+// renderOutput generates every output file for the model in memory.
+func renderOutput(model *Model, opts Options) *Output {
+	// Default to a folder named after the os when no output
+	// directory was given.
+	dir := opts.OutputDir
+	if dir == "" {
+		dir = model.Platform.Name
+	}
 
-	// Check our OS and read it's syscall.master file.
-	if runtime.GOOS == "freebsd" {
-		SyscallListBuf, err = ioutil.ReadFile("input/freebsd-syscall.master")
-		if err != nil {
-			log.Fatal(err)
-			return
-		}
+	format, err := lookupFormat(opts.Format)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if runtime.GOOS == "darwin" {
-		SyscallListBuf, err = ioutil.ReadFile("input/osx-syscall.master")
-		if err != nil {
-			log.Fatal(err)
-			return
-		}
+	out := newOutput(dir, format.Name, model.Platform.Name)
+
+	for i := range model.Entries {
+		model.Entries[i].SyscallMacros = opts.SyscallMacros
 	}
 
-	generateOutput(runtime.GOOS, SyscallListBuf)
+	format.Render(out, model, opts)
 
-	return
+	return out
+}
+
+// renderC generates the nextgen entry files and syscall tables.
+func renderC(out *Output, model *Model, opts Options) {
+	// Either amalgamate everything into a few large files or
+	// write one file per syscall entry.
+	if opts.Amalgamate {
+		createSyscallList(out, amalgamationName(model.Platform, ".h"), model, opts)
+		createAmalgamation(out, model, opts)
+	} else {
+		for _, entry := range model.definedEntries() {
+			writeEntry(out, entry, entry.SyscallName, model, opts)
+		}
+
+		createSyscallList(out, "syscall_list.h", model, opts)
+		createSyscallTables(out, model, opts)
+	}
 }
 
-func getConfig(os string) []byte {
-	var err error
-	var SyscallListBuf []byte
+func generateOutput(model *Model, opts Options) {
+	out := renderOutput(model, opts)
 
-	if os == "freebsd" {
-		SyscallListBuf, err = ioutil.ReadFile("input/freebsd-syscall.master")
+	// Print a single generated file instead of writing anything.
+	if opts.Stdout != "" {
+		name, err := stdoutFile(out, model, opts)
 		if err != nil {
 			log.Fatal(err)
-			return nil
+			return
 		}
+
+		os.Stdout.Write(out.Files[name])
+		return
 	}
 
-	if os == "darwin" {
-		SyscallListBuf, err = ioutil.ReadFile("input/osx-syscall.master")
+	// In check mode nothing is written, the output directory
+	// just has to match what would have been generated.
+	if opts.Check {
+		changes, err := out.changes()
 		if err != nil {
-			log.Fatal(err)
-			return nil
+			log.Fatal("Can't compare output files: ", err)
+			return
 		}
+
+		if len(changes) != 0 {
+			out.diff(os.Stdout, changes)
+			log.Printf("%d generated files in %s are out of date", len(changes), out.Dir)
+			os.Exit(1)
+		}
+		return
 	}
 
-	return SyscallListBuf
-}
+	// Only say what would be written in dry run mode.
+	if opts.DryRun {
+		changes, err := out.changes()
+		if err != nil {
+			log.Fatal("Can't compare output files: ", err)
+			return
+		}
 
-func main() {
-	var os = flag.String("os", "default", "The operating system to generate syscall entry for.")
-	flag.Parse()
-
-	// Check if no build options, were selected. If not just generate
-	// syscall entries for the operating system we are running on.
-	if *os == "default" {
-		log.Printf("No operating system selected, defaulting to: %s", runtime.GOOS)
-		defaultBuild()
+		for _, change := range changes {
+			fmt.Printf("%s %s\n", change.Action, out.path(change.Name))
+		}
 		return
 	}
 
-	// Load the config file into memory. We need the config file to know how
-	// to generate syscall entries. The config file contains number of args
-	// and syscall types, and more.
-	config := getConfig(*os)
-
-	// The user want's to generate syscall entries for a specific platform.
-	generateOutput(*os, config)
+	// Only write the files that changed and remove the ones
+	// that are no longer generated.
+	if err := out.write(); err != nil {
+		log.Fatal("Can't write output files: ", err)
+		return
+	}
 
 	return
 }
+
+func main() {
+	args := os.Args[1:]
+
+	// Without a command entrygen generates syscall entries, like it
+	// did before it had commands.
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runGenerate(args)
+		return
+	}
+
+	runCommand(args[0], args[1:])
+}
@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"text/template"
 )
 
 func TestGetPointerArgType(t *testing.T) {
@@ -35,3 +44,899 @@ func TestRemoveArgName(t *testing.T) {
 	}
 
 }
+
+func TestBuildSyscallTables(t *testing.T) {
+	slots := []Slot{{Number: 0, Name: "nosys", Status: statusNosys},
+		{Number: 1, Name: "exit", Status: statusOn},
+		{Number: 2, Name: "fork", Status: statusOn},
+		{Number: 3, Name: "enosys", Status: statusNosys},
+		{Number: 4, Name: "", Status: statusObsolete},
+		{Number: 5, Name: "read", Status: statusFiltered},
+		{Number: 6, Name: "close", Status: statusOn},
+		{Number: 1, Name: "nosys", Status: statusNosys}}
+	s := buildSyscallTables(slots)
+
+	if s.TotalSyscalls != 3 {
+		t.Errorf("Wrong dense table size: %d", s.TotalSyscalls)
+	}
+
+	if s.Syscall[2].Name != "entry_close" || s.Syscall[2].Counter != 2 {
+		t.Errorf("Dense table is not packed: %v", s.Syscall)
+	}
+
+	if s.TotalByNumber != 7 {
+		t.Errorf("Number table should cover syscall 0 through 6: %d", s.TotalByNumber)
+	}
+
+	if s.ByNumber[2].Number != 6 || s.ByNumber[2].Name != "entry_close" {
+		t.Errorf("Number table is not indexed by syscall number: %v", s.ByNumber)
+	}
+
+	if s.TotalByName != 3 || s.ByName[0].Name != "entry_close" || s.ByName[2].Counter != 2 {
+		t.Errorf("Name table is not sorted: %v", s.ByName)
+	}
+}
+
+func TestNewPlatform(t *testing.T) {
+	p := newPlatform("freebsd", "amd64")
+
+	if p.Display != "FreeBSD" {
+		t.Errorf("Wrong display name: %s", p.Display)
+	}
+
+	if p.Ident != "freebsd_amd64" {
+		t.Errorf("Wrong C identifier: %s", p.Ident)
+	}
+
+	if p.Guard != "FREEBSD_AMD64_SYSCALL_TABLE_H" {
+		t.Errorf("Wrong include guard: %s", p.Guard)
+	}
+
+	if cIdentifier("9front-386") != "_front_386" {
+		t.Errorf("Did not sanitize identifier: %s", cIdentifier("9front-386"))
+	}
+}
+
+func TestEntryFileName(t *testing.T) {
+	str := entryFileName("entry_{{name}}.c", "read")
+	if str != "entry_read.c" {
+		t.Errorf("Did not expand name: %s", str)
+	}
+
+	str = entryFileName("{{name}}/{{name}}.c", "wait4")
+	if str != "wait4/wait4.c" {
+		t.Errorf("Did not expand every name: %s", str)
+	}
+}
+
+func TestCreateOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nextgen", "src", "syscall", "freebsd")
+
+	if err := createOutputDir(dir); err != nil {
+		t.Errorf("Can't create nested output directory: %s", err)
+	}
+
+	if err := createOutputDir(dir); err != nil {
+		t.Errorf("Existing output directory should be reused: %s", err)
+	}
+
+	file := filepath.Join(dir, "syscall_list.h")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := createOutputDir(file); err == nil {
+		t.Errorf("A file should not be used as the output directory")
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	dir := t.TempDir()
+	custom := `{{ define "warning" }}/* custom warning */{{ end }}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "warning.txt"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := loadTemplate(dir, &Model{}, "syscall_list.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, Syscalls{Year: "2016"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "/* custom warning */") != true {
+		t.Errorf("Did not use the warning override")
+	}
+
+	if strings.Contains(buf.String(), "Copyright (c) 2016") != true {
+		t.Errorf("Did not fall back to the embedded copyright")
+	}
+}
+
+func TestSplitArgName(t *testing.T) {
+	ctype, name := splitArgName(" const char *path")
+	if ctype != "const char *" || name != "path" {
+		t.Errorf("Did not split pointer argument: %q %q", ctype, name)
+	}
+
+	ctype, name = splitArgName("socklen_t\t*anamelen")
+	if ctype != "socklen_t *" || name != "anamelen" {
+		t.Errorf("Did not split tab separated argument: %q %q", ctype, name)
+	}
+
+	ctype, name = splitArgName("int")
+	if ctype != "int" || name != "" {
+		t.Errorf("Argument without a name should keep it's type: %q %q", ctype, name)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	model := &Model{Platform: newPlatform("darwin", "arm64")}
+	text := `{{ upper "read" }} {{ cString "a\"b\n" }} {{ hex "16" }} [{{ "fd" | pad 4 }}] ` +
+		`{{ argKind "caddr_t" }} {{ isPointer "int" }} {{ model.Platform.Display }}`
+
+	tmpl, err := template.New("test").Funcs(templateFuncs(model)).Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `READ "a\"b\n" 0x10 [fd  ] pointer false macOS`
+	if buf.String() != expected {
+		t.Errorf("Wrong template output: %s", buf.String())
+	}
+}
+
+func TestShardEntries(t *testing.T) {
+	entries := make([]Entry, 7)
+	shards := shardEntries(entries, 3)
+
+	if len(shards) != 3 {
+		t.Fatalf("Wrong number of shards: %d", len(shards))
+	}
+
+	if len(shards[0]) != 3 || len(shards[1]) != 3 || len(shards[2]) != 1 {
+		t.Errorf("Entries are not spread over the shards: %d %d %d", len(shards[0]), len(shards[1]), len(shards[2]))
+	}
+
+	shards = shardEntries(entries[:2], 4)
+	if len(shards[3]) != 0 {
+		t.Errorf("Extra shards should be empty")
+	}
+}
+
+func TestAmalgamationRedefinition(t *testing.T) {
+	config := "38\tAUE_STAT\tCOMPAT\t{ int stat(char *path, struct ostat *ub); }\n" +
+		"188\tAUE_STAT\tSTD\t{ int stat(char *path, struct stat *ub); }\n"
+
+	model := parseModel(newPlatform("freebsd", "amd64"), []byte(config), Filter{})
+	out := renderOutput(model, Options{Format: "c", Amalgamate: true, Year: "2020"})
+
+	file := string(out.Files["syscalls_freebsd.c"])
+	if count := strings.Count(file, "struct syscall_entry entry_stat = {"); count != 1 {
+		t.Fatalf("entry_stat is defined %d times:\n%s", count, file)
+	}
+
+	if strings.Contains(file, ".syscall_number = 188,") != true {
+		t.Errorf("The last definition of stat should win:\n%s", file)
+	}
+}
+
+func TestSyscallTablesRedefinition(t *testing.T) {
+	config := "38\tAUE_STAT\tCOMPAT\t{ int stat(char *path, struct ostat *ub); }\n" +
+		"129\tAUE_TRUNCATE\tCOMPAT\t{ int truncate(char *path, long length); }\n" +
+		"188\tAUE_STAT\tSTD\t{ int stat(char *path, struct stat *ub); }\n" +
+		"200\tAUE_TRUNCATE\tSTD\t{ int truncate(char *path, int pad, off_t length); }\n" +
+		"479\tAUE_TRUNCATE\tSTD\t{ int truncate(char *path, off_t length); }\n"
+
+	model := parseModel(newPlatform("freebsd", "amd64"), []byte(config), Filter{})
+	entries := model.definedEntries()
+	s := buildSyscallTables(model.definedSlots())
+
+	if s.TotalSyscalls != len(entries) || s.TotalByName != len(entries) || len(s.ByNumber) != len(entries) {
+		t.Fatalf("Tables don't have one row per entry: %d entries, %+v", len(entries), s)
+	}
+
+	numbers := make(map[string]string)
+	for _, entry := range entries {
+		numbers["entry_"+strings.TrimSpace(entry.SyscallName)] = entry.EntryNumber
+	}
+	for _, name := range s.ByNumber {
+		if numbers[name.Name] != strconv.Itoa(name.Number) {
+			t.Errorf("Slot %d points at %s, which is syscall %s", name.Number, name.Name, numbers[name.Name])
+		}
+	}
+}
+
+func TestCopyrightYear(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "entry_read.c")
+	if err := ioutil.WriteFile(file, []byte(" * Copyright (c) 2016, Harrison Bowden"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if year := copyrightYear(file, Options{}); year != "2016" {
+		t.Errorf("Did not keep the year from the existing file: %s", year)
+	}
+
+	if year := copyrightYear(file, Options{Year: "2020"}); year != "2020" {
+		t.Errorf("Pinned year should win: %s", year)
+	}
+}
+
+func TestPinnedYear(t *testing.T) {
+	os.Setenv("SOURCE_DATE_EPOCH", "1500000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	if year, _ := pinnedYear(""); year != "2017" {
+		t.Errorf("Did not use SOURCE_DATE_EPOCH: %s", year)
+	}
+
+	if year, _ := pinnedYear("2018"); year != "2018" {
+		t.Errorf("The -year flag should win over SOURCE_DATE_EPOCH: %s", year)
+	}
+
+	if _, err := pinnedYear("18"); err == nil {
+		t.Errorf("Two digit year should be rejected")
+	}
+}
+
+func TestOutputWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	out := newOutput(dir, "c", "freebsd")
+	out.Files["entry_read.c"] = []byte("read")
+	out.Files["entry_creat.c"] = []byte("creat")
+	if err := out.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file that entrygen didn't generate must never be deleted.
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out = newOutput(dir, "c", "freebsd")
+	out.Files["entry_read.c"] = []byte("read")
+	out.Files["entry_write.c"] = []byte("write")
+	changes, err := out.changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := make(map[string]string)
+	for _, change := range changes {
+		actions[change.Name] = change.Action
+	}
+
+	if actions["entry_write.c"] != actionCreate || actions["entry_creat.c"] != actionDelete {
+		t.Errorf("Wrong changes: %v", actions)
+	}
+
+	if _, ok := actions["entry_read.c"]; ok {
+		t.Errorf("Unchanged file should not be rewritten")
+	}
+
+	if err = out.apply(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "entry_creat.c")); os.IsNotExist(err) != true {
+		t.Errorf("Stale entry file was not deleted")
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("File not in the manifest was deleted")
+	}
+
+	// Another format writing to the same directory keeps its own manifest.
+	printer := newOutput(dir, "printer", "freebsd")
+	printer.Files["print_freebsd.c"] = []byte("print")
+	if err = printer.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "entry_read.c")); err != nil {
+		t.Errorf("Another format's file was deleted")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n")
+	new := []byte("a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n")
+
+	expected := "--- old\n+++ new\n" +
+		"@@ -2,9 +2,10 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n i\n j\n+k\n"
+
+	if str := unifiedDiff("old", "new", old, new); str != expected {
+		t.Errorf("Wrong diff:\n%s", str)
+	}
+
+	if str := unifiedDiff("old", "new", old, old); str != "" {
+		t.Errorf("Same content should not have a diff:\n%s", str)
+	}
+
+	expected = "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if str := unifiedDiff("old", "new", nil, []byte("x\ny\n")); str != expected {
+		t.Errorf("Wrong diff for a new file:\n%s", str)
+	}
+}
+
+func TestStdoutFile(t *testing.T) {
+	model := &Model{Platform: newPlatform("freebsd", "amd64")}
+	out := newOutput("freebsd", "c", "freebsd")
+	out.Files["entry_read.c"] = nil
+	out.Files["freebsd_table.h"] = nil
+
+	opts := Options{EntryPattern: "entry_{{name}}.c", Stdout: "read"}
+	if name, err := stdoutFile(out, model, opts); err != nil || name != "entry_read.c" {
+		t.Errorf("Did not find syscall entry: %s %v", name, err)
+	}
+
+	opts.Stdout = "table"
+	if name, err := stdoutFile(out, model, opts); err != nil || name != "freebsd_table.h" {
+		t.Errorf("Did not find syscall table: %s %v", name, err)
+	}
+
+	opts.Stdout = "write"
+	if _, err := stdoutFile(out, model, opts); err == nil {
+		t.Errorf("Missing syscall should be an error")
+	}
+}
+
+func TestSyscallGroup(t *testing.T) {
+	groups := map[string]string{"read_nocancel": "file",
+		"statfs":       "fs",
+		"getauid":      "security",
+		"getuid":       "process",
+		"recvfrom":     "net",
+		"__mac_execve": "security",
+		"shm_open":     "ipc",
+		"kevent64":     "event"}
+
+	for name, group := range groups {
+		if syscallGroup(name) != group {
+			t.Errorf("%s should be in group %s, not %s", name, group, syscallGroup(name))
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f, err := parseFilter("/^recv/,socket*", "recvmsg", "0-100,200", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.match("recvfrom", 29, "net") != true || f.match("socket", 97, "net") != true {
+		t.Errorf("Did not match -only patterns")
+	}
+
+	if f.match("recvmsg", 27, "net") {
+		t.Errorf("Did not exclude syscall")
+	}
+
+	if f.match("socketpair", 135, "net") {
+		t.Errorf("Syscall outside of the range should not match")
+	}
+
+	f, _ = parseFilter("", "", "", "ipc")
+	if f.match("read", 3, "file") || f.match("semop", 255, "ipc") != true {
+		t.Errorf("Did not filter by group")
+	}
+
+	if _, err = parseFilter("", "", "200-100", ""); err == nil {
+		t.Errorf("Backwards range should be an error")
+	}
+
+	if _, err = parseFilter("", "", "", "nope"); err == nil {
+		t.Errorf("Unknown group should be an error")
+	}
+}
+
+func TestValidateModel(t *testing.T) {
+	config := "3\tAUE_NULL\tSTD\t{ int read(int fd); }\n" +
+		"4\tAUE_NULL\tSTD\t{ int frob(struct frob *f); }\n" +
+		"5\tAUE_NULL\tSTD\t{ int read(void); }\n" +
+		"38\tAUE_STAT\tCOMPAT\t{ int stat(char *path, struct ostat *ub); }\n" +
+		"188\tAUE_STAT\tSTD\t{ int stat(char *path, struct stat *ub); }\n"
+
+	model := parseModel(newPlatform("freebsd", "amd64"), []byte(config), Filter{})
+
+	problems := validateModel(model)
+	if len(problems) != 2 {
+		t.Fatalf("Expected 2 problems, got %v", problems)
+	}
+
+	if problems[0] != "frob: argument 1 (struct frob * f) has no type mapping" {
+		t.Errorf("Unexpected problem: %s", problems[0])
+	}
+
+	if problems[1] != "read: defined more than once" {
+		t.Errorf("Unexpected problem: %s", problems[1])
+	}
+}
+
+func TestNormalizeFreeBSD(t *testing.T) {
+	config := "; comment\n" +
+		"3\tAUE_READ\tSTD|CAPENABLED {\n" +
+		"\t\tssize_t read(\n" +
+		"\t\t    int fd,\n" +
+		"\t\t    _Out_writes_bytes_(nbyte) void *buf,\n" +
+		"\t\t    size_t nbyte\n" +
+		"\t\t);\n" +
+		"\t}\n" +
+		"4\tAUE_WRITE\tSTD\t{ ssize_t write(int fd, const void *buf, \\\n" +
+		"\t\t\t\t    size_t nbyte); }\n"
+
+	expected := "; comment\n" +
+		"3\tAUE_READ\tSTD|CAPENABLED { ssize_t read(int fd, void *buf, size_t nbyte); }\n" +
+		"4\tAUE_WRITE\tSTD\t{ ssize_t write(int fd, const void *buf, size_t nbyte); }\n"
+
+	if got := string(normalizeFreeBSD([]byte(config))); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	for i, name := range []string{"read", "write"} {
+		if getSyscallName(strings.Split(expected, "\n")[i+1]) != name {
+			t.Errorf("Joined %s does not parse", name)
+		}
+	}
+}
+
+func TestLookupBackend(t *testing.T) {
+	for _, name := range []string{"darwin", "macos", "OSX", "xnu"} {
+		b, err := lookupBackend(name)
+		if err != nil || b.Name != "darwin" {
+			t.Errorf("%s should be darwin, got %q %v", name, b.Name, err)
+		}
+	}
+
+	if _, err := lookupBackend("linux"); err == nil {
+		t.Errorf("Unknown operating system should be an error")
+	}
+
+	b, _ := lookupBackend("freebsd")
+	types, args := mapTypes([]string{"int fd", "mode_t mode"}, b.Types)
+	if types[1] != "INT" || args[1] != "&generate_int" || types[0] != "INT" {
+		t.Errorf("Wrong type mappings: %v %v", types, args)
+	}
+}
+
+func TestUpdateConditionals(t *testing.T) {
+	var c []string
+	c = updateConditionals(c, "#if SOCKETS")
+	c = updateConditionals(c, "#ifdef __LP64__")
+	if strings.Join(c, ",") != "SOCKETS,defined(__LP64__)" {
+		t.Errorf("Wrong conditionals: %v", c)
+	}
+
+	c = updateConditionals(c, "#endif")
+	c = updateConditionals(c, "#else")
+	if strings.Join(c, ",") != "!SOCKETS" {
+		t.Errorf("#else should negate the condition: %v", c)
+	}
+
+	c = updateConditionals(c, "#endif /* SOCKETS */")
+	if len(c) != 0 {
+		t.Errorf("#endif should pop the condition: %v", c)
+	}
+
+	if extractAuditEvent("3\tAUE_NULL\tALL\t{ int read(int fd); }") != "AUE_NULL" {
+		t.Errorf("Did not extract audit event")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	config := "#if SOCKETS\n" +
+		"1\tAUE_EXIT\tALL\t{ void exit(int rval) NO_SYSCALL_STUB; }\n" +
+		"#endif\n" +
+		"2\tAUE_NULL\tALL\t{ int nosys(void); }\n" +
+		"3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n"
+
+	model := parseModel(newPlatform("darwin", "arm64"), []byte(config), Filter{})
+
+	data, err := exportModel(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := importModel(data, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := exportModel(imported)
+	if bytes.Equal(data, again) != true {
+		t.Errorf("Model changed in a round trip:\n%s\n%s", data, again)
+	}
+
+	if imported.Entries[1].ArgArray[1].ArgSymbol != "SECOND_ARG" || imported.Slots[0].Conditionals[0] != "SOCKETS" {
+		t.Errorf("Imported model is missing fields: %+v", imported)
+	}
+
+	if _, err = importModel([]byte(`{"schema": 99, "platform": "darwin"}`), Filter{}); err == nil {
+		t.Errorf("Unknown schema version should be an error")
+	}
+
+	// Params edited by hand with just a C type get the rest mapped.
+	edited := `{"schema": 1, "platform": "darwin", "entries": [{"number": 6, "name": "close", "status": "on",
+		"params": [{"name": "fd", "c_type": "int"}]}]}`
+	imported, err = importModel([]byte(edited), Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arg := imported.Entries[0].ArgArray[0]; arg.ArgType != "INT" || arg.GetArg != "&generate_int" {
+		t.Errorf("Type of a hand edited param wasn't mapped: %+v", arg)
+	}
+
+	unknown := strings.Replace(edited, `"c_type": "int"`, `"c_type": "struct frob *"`, 1)
+	if _, err = importModel([]byte(unknown), Filter{}); err == nil {
+		t.Errorf("A param without a type mapping should be an error")
+	}
+}
+
+func TestSyzlangCalls(t *testing.T) {
+	config := "3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"5\tAUE_OPEN_RWTC\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n" +
+		"6\tAUE_NULL\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n"
+
+	calls := syzlangCalls(parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{}))
+	if len(calls) != 3 {
+		t.Fatalf("Expected 3 calls, got %d", len(calls))
+	}
+
+	if read := strings.Join(calls[0].Args, ", "); read != "fd fd, cbuf buffer[inout], nbyte len[cbuf]" {
+		t.Errorf("Wrong read description: %s", read)
+	}
+
+	if calls[2].Ret != "fd" || calls[2].Args[0] != "path ptr[in, filename]" {
+		t.Errorf("Wrong open description: %+v", calls[2])
+	}
+
+	if calls[1].Skipped == "" {
+		t.Errorf("First open should be skipped")
+	}
+}
+
+func TestSyzDescriptions(t *testing.T) {
+	d := &SyzDescriptions{Calls: make(map[string][]SyzArg),
+		Flags:     make(map[string][]string),
+		Resources: make(map[string]string)}
+
+	d.parse("resource fd[int32]: -1\n" +
+		"resource sock[fd]\n" +
+		"read$dir(fd fd_dir, buf buffer[out], count len[buf])\n" +
+		"read(fd fd, buf buffer[out], count len[buf]) # comment\n" +
+		"socket(domain flags[socket_domain], type int32, proto int32) sock\n" +
+		"socket_domain = AF_INET, AF_UNIX\n" +
+		"foo {\n\tbar\tint32 (in)\n}\n")
+
+	if len(d.Calls) != 2 || d.Calls["read"][1].Type != "buffer[out]" {
+		t.Fatalf("Wrong calls: %+v", d.Calls)
+	}
+
+	if d.rootResource("sock") != "fd" {
+		t.Errorf("sock should be based on fd")
+	}
+
+	config := "3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"4\tAUE_NULL\tALL\t{ user_ssize_t write(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"97\tAUE_SOCKET\tALL\t{ int socket(int domain, int type, int protocol); }\n"
+
+	model := parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{})
+	missing := d.enrichModel(model)
+
+	if len(missing) != 1 || missing[0] != "write" {
+		t.Errorf("write should be missing a description: %v", missing)
+	}
+
+	read := model.Entries[0].ArgArray
+	if read[0].Kind != "fd" || read[1].Direction != "out" || read[2].Kind != "len" || read[2].LenOf != "cbuf" {
+		t.Errorf("read was not enriched: %+v", read)
+	}
+
+	socket := model.Entries[2].ArgArray
+	if socket[0].Flags != "socket_domain" || len(model.FlagSets["socket_domain"]) != 2 {
+		t.Errorf("socket flags were not enriched: %+v", socket)
+	}
+}
+
+func TestBuildTrinity(t *testing.T) {
+	config := "1\tAUE_EXIT\tALL\t{ void exit(int rval) NO_SYSCALL_STUB; }\n" +
+		"3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"29\tAUE_RECVFROM\tALL\t{ int recvfrom(int s, void *buf, size_t len, int flags, struct sockaddr *from, int *fromlenaddr); }\n" +
+		"30\tAUE_NULL\tALL\t{ int exit(int rval); }\n"
+
+	model := parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{})
+	tr := buildTrinity(model)
+
+	if len(tr.Entries) != 4 || tr.Entries[3].Flags != "AVOID_SYSCALL" || tr.Entries[0].Skipped == "" {
+		t.Fatalf("Wrong entries: %+v", tr.Entries)
+	}
+
+	var types []string
+	for _, arg := range tr.Entries[2].Args {
+		types = append(types, arg.Type)
+	}
+	if strings.Join(types, " ") != "ARG_FD ARG_ADDRESS ARG_LEN ARG_UNDEFINED ARG_SOCKADDR ARG_ADDRESS" {
+		t.Errorf("Wrong recvfrom arg types: %v", types)
+	}
+
+	// Paths are guessed by name and type without a syzkaller description.
+	paths := parseModel(newPlatform("darwin", "amd64"), []byte(
+		"5\tAUE_OPEN\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n"+
+			"6\tAUE_NULL\tALL\t{ int chflags(const char *name, int flags); }\n"), Filter{})
+	for _, entry := range paths.Entries {
+		if typ := trinityArgType(entry.ArgArray, 0, nil); typ != "ARG_PATHNAME" {
+			t.Errorf("%s: path is %s", entry.SyscallName, typ)
+		}
+	}
+
+	if len(tr.Table) != 3 || tr.Table[1].Number != 29 || tr.Table[2].Number != 30 || tr.Table[2].Ident != "syscall_exit" {
+		t.Errorf("Wrong table: %+v", tr.Table)
+	}
+}
+
+func TestBuildGoPackage(t *testing.T) {
+	config := "20\tAUE_GETPID\tALL\t{ int getpid(void); }\n" +
+		"3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"380\tAUE_MAC_EXECVE\tALL\t{ int __mac_execve(char *fname, char **argp, char **envp, struct mac *mac_p); }\n"
+
+	p := buildGoPackage(parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{}), "sys")
+
+	if p.Funcs[0].Caller != "RawSyscall" || p.Funcs[0].Const != "SYS_GETPID" {
+		t.Errorf("getpid should use RawSyscall: %+v", p.Funcs[0])
+	}
+
+	read := p.Funcs[1]
+	if strings.Join(read.Params, ", ") != "fd int32, cbuf unsafe.Pointer, nbyte uintptr" || read.Caller != "Syscall" {
+		t.Errorf("Wrong read wrapper: %+v", read)
+	}
+
+	if p.Funcs[2].Name != "MacExecve" || p.Funcs[2].Caller != "Syscall6" || len(p.Funcs[2].Args) != 6 {
+		t.Errorf("Wrong __mac_execve wrapper: %+v", p.Funcs[2])
+	}
+}
+
+func TestBuildRust(t *testing.T) {
+	config := "3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"4\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n"
+
+	r := buildRust(parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{}))
+
+	if len(r.Entries) != 2 || len(r.Consts) != 1 || r.Consts[0].Name != "SYS_READ" || r.Consts[0].Value != "4" {
+		t.Errorf("Wrong entries or constants: %+v %+v", r.Entries, r.Consts)
+	}
+
+	if strings.Join(r.ArgTypes, ",") != "Address,Int,Unknown" {
+		t.Errorf("Wrong ArgType variants: %v", r.ArgTypes)
+	}
+
+	arg := r.Entries[0].Args[1]
+	if arg.Name != `"cbuf"` || arg.Kind != "Pointer" || arg.Direction != "InOut" || arg.ArgType != "Address" {
+		t.Errorf("Wrong arg: %+v", arg)
+	}
+}
+
+func TestBuildPrinters(t *testing.T) {
+	config := "5\tAUE_OPEN\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n" +
+		"6\tAUE_CLOSE\tALL\t{ int close(int fd); }\n" +
+		"7\tAUE_NULL\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n" +
+		"8\tAUE_NULL\tALL\t{ int unmount(const char *target, int flags); }\n" +
+		"9\tAUE_NULL\tALL\t{ int chflags(const char *name, int flags); }\n" +
+		"10\tAUE_NULL\tALL\t{ int read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n"
+
+	model := parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{})
+	model.Entries[2].ArgArray[1].Kind = "flags"
+	model.Entries[2].ArgArray[1].Flags = "open_flags"
+	model.FlagSets = map[string][]string{"open_flags": {"O_RDONLY", "O_WRONLY"}}
+
+	p := buildPrinters(model)
+
+	if len(p.Entries) != 6 || p.Entries[2].Ident != "print_entry_open" || p.Entries[0].Skipped == "" {
+		t.Fatalf("Wrong entries: %+v", p.Entries)
+	}
+
+	var printers []string
+	for _, arg := range p.Entries[2].Args {
+		printers = append(printers, arg.Printer)
+	}
+	if strings.Join(printers, ",") != "string,flags,octal" {
+		t.Errorf("Wrong printers: %v", printers)
+	}
+
+	if p.Entries[3].Args[0].Printer != "string" || p.Entries[4].Args[0].Printer != "string" ||
+		p.Entries[5].Args[1].Printer != "pointer" {
+		t.Errorf("Paths without -syz are not guessed: %+v", p.Entries[3:])
+	}
+
+	// Flags without a known flag set are printed in hex.
+	if p.Entries[3].Args[1].Printer != "hex" || printerFor(Arg{Kind: "flags", Flags: "nope"}, model.FlagSets) != "hex" {
+		t.Errorf("Flags without a flag set are not hex: %+v", p.Entries[3].Args)
+	}
+
+	// Only flag tables need the headers defining flag values.
+	file := string(renderOutput(model, Options{Format: "printer", Year: "2020"}).Files["print_darwin.c"])
+	if strings.Contains(file, "#include <fcntl.h>") != true || strings.Contains(file, "print_flags(") != true {
+		t.Errorf("Flag tables are missing their headers:\n%s", file)
+	}
+
+	model.FlagSets = nil
+	file = string(renderOutput(model, Options{Format: "printer", Year: "2020"}).Files["print_darwin.c"])
+	if strings.Contains(file, "#include <fcntl.h>") || strings.Contains(file, "print_flags(") {
+		t.Errorf("Printers without flag tables include what they don't use:\n%s", file)
+	}
+
+	if len(p.Flags) != 1 || p.Flags[0].Ident != "open_flags_names" || len(p.Flags[0].Values) != 2 {
+		t.Errorf("Wrong flag tables: %+v", p.Flags)
+	}
+}
+
+func TestBuildRepro(t *testing.T) {
+	config := "5\tAUE_OPEN\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n" +
+		"6\tAUE_NULL\tALL\t{ int open(user_addr_t path, int flags, int mode); }\n" +
+		"7\tAUE_NULL\tALL\t{ int many(int a, int b, int c, int d, int e, int f, int g); }\n"
+
+	r := buildRepro(parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{}))
+
+	if len(r.Params) != 6 || len(r.Entries) != 3 {
+		t.Fatalf("Wrong params or entries: %v %+v", r.Params, r.Entries)
+	}
+
+	open := r.Entries[1]
+	if open.Ident != "repro_call_open" || strings.Join(open.Args, ",") != "a1,a2,a3" || len(open.Unused) != 3 {
+		t.Errorf("Wrong helper: %+v", open)
+	}
+
+	if r.Entries[0].Skipped == "" || r.Entries[2].Skipped == "" {
+		t.Errorf("Redefined and seven argument syscalls aren't skipped: %+v", r.Entries)
+	}
+}
+
+func TestBuildDocs(t *testing.T) {
+	config := "0\tAUE_NULL\tALL\t{ int nosys(void); }   { indirect syscall }\n" +
+		"#if SOCKETS\n" +
+		"5\tAUE_OPEN\tCOMPAT4\t{ int open(user_addr_t path, int flags, int mode); }\n" +
+		"#endif\n" +
+		"11\tAUE_NULL\tOBSOL\texecv\n" +
+		"38\tAUE_STAT\tCOMPAT\t{ int stat(char *path, struct ostat *ub); }\n" +
+		"188\tAUE_STAT\tSTD\t{ int stat(char *path, struct stat *ub); }\n"
+
+	d := buildDocs(parseModel(newPlatform("freebsd", "amd64"), []byte(config), Filter{}))
+
+	if len(d.Calls) != 5 || d.On != 2 || d.Superseded != 1 || d.Nosys != 1 || d.Obsolete != 1 {
+		t.Fatalf("Wrong calls or counts: %+v", d)
+	}
+
+	if d.Calls[0].Reason != "nosys placeholder, indirect syscall" || d.Calls[2].Reason != "OBSOL execv" {
+		t.Errorf("Wrong reasons: %q %q", d.Calls[0].Reason, d.Calls[2].Reason)
+	}
+
+	open := d.Calls[1]
+	if open.Prototype != "int open(user_addr_t path, int flags, int mode)" || open.Compat != "COMPAT_FREEBSD4" ||
+		strings.Join(open.Conditionals, ",") != "SOCKETS" || len(open.Args) != 3 {
+		t.Errorf("Wrong reference: %+v", open)
+	}
+
+	if open.Args[0].Generator != "generate_ptr" {
+		t.Errorf("Wrong generator: %+v", open.Args[0])
+	}
+
+	if stat := d.Calls[3]; stat.Status != docSuperseded || stat.Reason != "stat 38 is skipped, stat 188 defines it again" ||
+		len(stat.Args) != 0 || d.Calls[4].Status != statusOn {
+		t.Errorf("Redefined stat isn't superseded: %+v", d.Calls[3:])
+	}
+}
+
+func TestExportSQL(t *testing.T) {
+	config := "3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n" +
+		"8\tAUE_NULL\tALL\t{ int enosys(void); }   { old creat }\n"
+
+	darwin := parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{})
+	freebsd := parseModel(newPlatform("freebsd", "amd64"), []byte(config), Filter{})
+
+	data, err := exportSQL([]*Model{darwin, freebsd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql := string(data)
+	for _, want := range []string{
+		"INSERT INTO platforms VALUES (2, 'freebsd', 'FreeBSD', 'amd64');",
+		"INSERT INTO syscalls VALUES (2, 1, 8, 'enosys', 'nosys', NULL, NULL, 'AUE_NULL', 'ALL', 'old creat', NULL, 0);",
+		"INSERT INTO params VALUES (1, 2, 'cbuf', 2, 'pointer', 'inout', NULL, NULL, NULL);",
+	} {
+		if strings.Contains(sql, want) != true {
+			t.Errorf("SQL export is missing %q", want)
+		}
+	}
+
+	if strings.Count(sql, "INSERT INTO types") != 6 {
+		t.Errorf("Types aren't shared within a platform:\n%s", sql)
+	}
+
+	if _, err = exportSQL([]*Model{darwin, darwin}); err == nil {
+		t.Errorf("Exporting a platform twice should be an error")
+	}
+}
+
+func TestSQLiteRecord(t *testing.T) {
+	for v, want := range map[uint64]string{0: "00", 127: "7f", 128: "8100", 16383: "ff7f", 1 << 63: "c08080808080808000"} {
+		if got := fmt.Sprintf("%x", sqliteVarint(v)); got != want {
+			t.Errorf("varint %d: got %s, want %s", v, got, want)
+		}
+	}
+
+	record := sqliteRecord([]interface{}{nil, int64(1), int64(300), "ab"})
+	if got := fmt.Sprintf("%x", record); got != "0500090211012c6162" {
+		t.Errorf("Wrong record: %s", got)
+	}
+
+	if sqliteCompare([]interface{}{nil}, []interface{}{int64(-5)}) >= 0 ||
+		sqliteCompare([]interface{}{int64(9)}, []interface{}{"1"}) >= 0 ||
+		sqliteCompare([]interface{}{"a", int64(2)}, []interface{}{"a", int64(1)}) <= 0 {
+		t.Errorf("Index keys are in the wrong order")
+	}
+}
+
+func TestExportSQLite(t *testing.T) {
+	config := "3\tAUE_NULL\tALL\t{ user_ssize_t read(int fd, user_addr_t cbuf, user_size_t nbyte); }\n"
+
+	darwin := parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{})
+	freebsd := parseModel(newPlatform("freebsd", "amd64"), []byte(config), Filter{})
+
+	data, err := exportSQLite([]*Model{darwin, freebsd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.HasPrefix(string(data), "SQLite format 3\x00") != true || len(data)%sqlitePageSize != 0 ||
+		int(binary.BigEndian.Uint32(data[28:])) != len(data)/sqlitePageSize {
+		t.Fatalf("Wrong database header: %x", data[:100])
+	}
+
+	// Page 1 is the schema, a leaf with the five tables and five indexes.
+	if data[100] != sqliteTableLeaf || binary.BigEndian.Uint16(data[103:]) != 10 {
+		t.Errorf("Wrong schema page: %x", data[100:108])
+	}
+
+	if _, err = exportSQLite([]*Model{darwin, darwin}); err == nil {
+		t.Errorf("Exporting a platform twice should be an error")
+	}
+}
+
+func TestDefaultMasters(t *testing.T) {
+	for _, backend := range backends {
+		if _, err := readDefaultMaster(backend.Input); err != nil {
+			t.Errorf("%s: default master file isn't embedded: %v", backend.Name, err)
+		}
+	}
+}
+
+func TestExtractTotalArgs(t *testing.T) {
+	protos := map[string]string{"int fork(void)": "0",
+		"int sync()":                     "0",
+		"int close(int fd)":              "1",
+		"void exit(int rval)":            "1",
+		"int link(char *path, char *to)": "2"}
+
+	for proto, expected := range protos {
+		if count := extractTotalArgs(proto); count != expected {
+			t.Errorf("%s should have %s arguments, got %s", proto, expected, count)
+		}
+	}
+
+	config := "6\tAUE_CLOSE\tALL\t{ int close(int fd); }\n"
+	model := parseModel(newPlatform("darwin", "amd64"), []byte(config), Filter{})
+
+	args := model.Entries[0].ArgArray
+	if len(args) != 1 || args[0].CType != "int" || args[0].ArgName != "fd" || args[0].ArgType != "INT" {
+		t.Errorf("close should have one int fd argument: %+v", args)
+	}
+}
@@ -0,0 +1,178 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter selects which syscalls from the master file are generated.
+// Syscalls that are filtered out become holes, so they keep their
+// numbers in the generated tables.
+type Filter struct {
+	Only    []pattern // Only generate syscalls matching one of these.
+	Exclude []pattern // Never generate syscalls matching one of these.
+	Ranges  [][2]int  // Only generate syscalls numbered inside one of these.
+	Groups  []string  // Only generate syscalls in one of these groups.
+}
+
+// parseFilter creates a filter from the comma separated values of the
+// -only, -exclude, -range and -group flags.
+func parseFilter(only string, exclude string, ranges string, groups string) (Filter, error) {
+	var f Filter
+	var err error
+
+	if f.Only, err = parsePatterns(only); err != nil {
+		return f, err
+	}
+
+	if f.Exclude, err = parsePatterns(exclude); err != nil {
+		return f, err
+	}
+
+	for _, r := range splitList(ranges) {
+		bounds := strings.SplitN(r, "-", 2)
+		if len(bounds) == 1 {
+			bounds = append(bounds, bounds[0])
+		}
+
+		low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return f, fmt.Errorf("invalid syscall number range %q", r)
+		}
+
+		high, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil || high < low {
+			return f, fmt.Errorf("invalid syscall number range %q", r)
+		}
+
+		f.Ranges = append(f.Ranges, [2]int{low, high})
+	}
+
+	for _, group := range splitList(groups) {
+		if isGroup(group) != true {
+			return f, fmt.Errorf("unknown syscall group %q", group)
+		}
+		f.Groups = append(f.Groups, group)
+	}
+
+	return f, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// pattern matches syscall names with either a glob or a regular expression.
+type pattern struct {
+	glob string
+	reg  *regexp.Regexp
+}
+
+func (p pattern) match(name string) bool {
+	if p.reg != nil {
+		return p.reg.MatchString(name)
+	}
+
+	ok, _ := filepath.Match(p.glob, name)
+	return ok
+}
+
+// parsePatterns parses a list of globs, a pattern between slashes
+// like /^kevent/ is a regular expression instead.
+func parsePatterns(list string) ([]pattern, error) {
+	var patterns []pattern
+	for _, item := range splitList(list) {
+		if len(item) > 1 && strings.HasPrefix(item, "/") && strings.HasSuffix(item, "/") {
+			reg, err := regexp.Compile(item[1 : len(item)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %s", item, err)
+			}
+			patterns = append(patterns, pattern{reg: reg})
+			continue
+		}
+
+		if _, err := filepath.Match(item, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %s", item, err)
+		}
+		patterns = append(patterns, pattern{glob: item})
+	}
+
+	return patterns, nil
+}
+
+// match reports whether the syscall should be generated.
+func (f Filter) match(name string, number int, group string) bool {
+	if len(f.Only) != 0 && matchAny(f.Only, name) != true {
+		return false
+	}
+
+	if matchAny(f.Exclude, name) {
+		return false
+	}
+
+	if len(f.Ranges) != 0 {
+		inside := false
+		for _, r := range f.Ranges {
+			if number >= r[0] && number <= r[1] {
+				inside = true
+				break
+			}
+		}
+
+		if inside != true {
+			return false
+		}
+	}
+
+	if len(f.Groups) != 0 {
+		inside := false
+		for _, g := range f.Groups {
+			if g == group {
+				inside = true
+				break
+			}
+		}
+
+		if inside != true {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchAny(patterns []pattern, name string) bool {
+	for _, p := range patterns {
+		if p.match(name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format is an output backend that renders the model into files.
+type Format struct {
+	Name   string
+	Help   string
+	Render func(out *Output, model *Model, opts Options)
+}
+
+var outputFormats = []Format{
+	{"c", "nextgen C entry files and syscall tables, the default", renderC},
+	{"syzlang", "syzkaller syscall descriptions with a .const file", renderSyzlang},
+	{"trinity", "Trinity struct syscallentry definitions and their table", renderTrinity},
+	{"go", "a Go package with a typed wrapper for every syscall", renderGo},
+	{"rust", "a Rust module with the syscall table and numbers", renderRust},
+	{"printer", "C functions that print a syscall and its arguments like strace", renderPrinters},
+	{"repro", "C helpers and a skeleton program that replay a fuzzer log", renderRepro},
+	{"docs", "a Markdown and HTML reference of every syscall", renderDocs},
+}
+
+// lookupFormat returns the output format called name.
+func lookupFormat(name string) (Format, error) {
+	var names []string
+	for _, f := range outputFormats {
+		if f.Name == name {
+			return f, nil
+		}
+		names = append(names, f.Name)
+	}
+
+	return Format{}, fmt.Errorf("Unknown output format %q, expected one of %s", name, strings.Join(names, ", "))
+}
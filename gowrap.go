@@ -0,0 +1,227 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"log"
+	"strings"
+)
+
+// GoPackage is the data for the Go syscall wrapper template.
+type GoPackage struct {
+	Platform   Platform
+	Package    string
+	UsesUnsafe bool
+	Consts     []GoConst
+	Funcs      []GoFunc
+}
+
+type GoConst struct {
+	Name  string
+	Value string
+}
+
+// GoFunc is the typed wrapper of one syscall.
+type GoFunc struct {
+	Name    string   // The exported name of the wrapper, ie Read.
+	Syscall string   // The name of the syscall, ie read.
+	Number  string   // The number of the syscall.
+	Const   string   // The SYS_ constant of the syscall.
+	Caller  string   // The function of the syscall package used, ie Syscall6.
+	Params  []string // Each parameter as "name type".
+	Strings []string // Parameters that are converted to C strings.
+	Args    []string // The uintptr arguments passed to Caller.
+	Skip
+}
+
+// Syscalls that never block, they are called with RawSyscall.
+var goRawSyscalls = map[string]bool{"getpid": true, "getppid": true, "getuid": true, "geteuid": true,
+	"getgid": true, "getegid": true, "getpgrp": true, "getpgid": true, "getsid": true, "gettid": true,
+	"issetugid": true, "umask": true, "thread_selfid": true, "getdtablesize": true, "thr_self": true}
+
+// Names a parameter can't have, Go keywords and identifiers the
+// wrappers use.
+var goReserved = map[string]bool{"break": true, "case": true, "chan": true, "const": true,
+	"continue": true, "default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true, "interface": true, "map": true,
+	"package": true, "range": true, "return": true, "select": true, "struct": true, "switch": true,
+	"type": true, "var": true, "err": true, "e1": true, "r1": true, "syscall": true, "unsafe": true,
+	"uintptr": true, "string": true, "int": true, "int8": true, "int16": true, "int32": true, "int64": true}
+
+// goName turns a syscall name into an exported Go name, ie
+// __mac_execve to MacExecve.
+func goName(name string) string {
+	var buffer strings.Builder
+	for _, part := range strings.Split(cIdentifier(name), "_") {
+		if part == "" {
+			continue
+		}
+		buffer.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return buffer.String()
+}
+
+// goParamType maps an argument to the Go type of its wrapper parameter,
+// using the same int sizes as the syzlang output.
+func goParamType(arg Arg) string {
+	switch arg.Kind {
+	case "path", "string":
+		return "string"
+	case "pointer":
+		return "unsafe.Pointer"
+	case "fd", "pid":
+		return "int"
+	}
+
+	switch syzIntType(arg.CType) {
+	case "int8":
+		return "int8"
+	case "int16":
+		return "int16"
+	case "int32":
+		return "int32"
+	case "int64":
+		return "int64"
+	}
+
+	return "uintptr"
+}
+
+// goCaller picks the syscall package function that takes enough
+// arguments and pads the arguments to its count.
+func goCaller(name string, args []string) (string, []string, error) {
+	callers := []struct {
+		name string
+		raw  string
+		max  int
+	}{{"Syscall", "RawSyscall", 3}, {"Syscall6", "RawSyscall6", 6}, {"Syscall9", "", 9}}
+
+	for _, c := range callers {
+		if len(args) > c.max {
+			continue
+		}
+
+		for len(args) < c.max {
+			args = append(args, "0")
+		}
+
+		if goRawSyscalls[name] && c.raw != "" {
+			return c.raw, args, nil
+		}
+		return c.name, args, nil
+	}
+
+	return "", nil, fmt.Errorf("%s has %d arguments, the syscall package supports 9", name, len(args))
+}
+
+// buildGoPackage converts the model to Go wrappers. Two syscalls whose
+// names turn into the same Go name, ie __mac_execve and mac_execve, keep
+// the first one.
+func buildGoPackage(model *Model, pkg string) GoPackage {
+	p := GoPackage{Platform: model.Platform, Package: pkg}
+	skips := model.skips(len(argSymbols))
+	seen := make(map[string]string)
+
+	for i, entry := range model.Entries {
+		name := strings.TrimSpace(entry.SyscallName)
+		f := GoFunc{Name: goName(name),
+			Syscall: name,
+			Number:  entry.EntryNumber,
+			Const:   "SYS_" + strings.ToUpper(cIdentifier(name))}
+
+		if first, ok := seen[f.Name]; ok && skips[i] == "" {
+			f.Skipped = fmt.Sprintf("%s %s is skipped, %s is already defined by syscall number %s",
+				name, entry.EntryNumber, f.Name, first)
+		} else {
+			f.Skipped = skips[i]
+		}
+
+		if f.Skipped != "" {
+			p.Funcs = append(p.Funcs, f)
+			continue
+		}
+
+		used := make(map[string]bool)
+		var args []string
+		for j, arg := range entry.ArgArray {
+			param := arg.ArgName
+			if param == "" {
+				param = fmt.Sprintf("a%d", j)
+			}
+			for goReserved[param] || used[param] {
+				param = param + "_"
+			}
+			used[param] = true
+
+			typ := goParamType(arg)
+			f.Params = append(f.Params, param+" "+typ)
+
+			switch typ {
+			case "string":
+				f.Strings = append(f.Strings, param)
+				args = append(args, "uintptr(unsafe.Pointer("+param+"p))")
+				p.UsesUnsafe = true
+			case "unsafe.Pointer":
+				args = append(args, "uintptr("+param+")")
+				p.UsesUnsafe = true
+			default:
+				args = append(args, "uintptr("+param+")")
+			}
+		}
+
+		var err error
+		f.Caller, f.Args, err = goCaller(name, args)
+		if err != nil {
+			f.Skipped = err.Error()
+			p.Funcs = append(p.Funcs, f)
+			continue
+		}
+
+		seen[f.Name] = entry.EntryNumber
+		p.Consts = append(p.Consts, GoConst{Name: f.Const, Value: entry.EntryNumber})
+		p.Funcs = append(p.Funcs, f)
+	}
+
+	return p
+}
+
+// renderGo generates a Go package with a wrapper for every syscall.
+func renderGo(out *Output, model *Model, opts Options) {
+	t, err := loadTemplate(opts.TemplateDir, model, "go.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pkg := opts.GoPackage
+	if pkg == "" {
+		pkg = "syscalls"
+	}
+
+	file := "zsyscall_" + model.Platform.Name + "_" + model.Platform.Arch + ".go"
+	if err = out.execute(file, t, buildGoPackage(model, pkg)); err != nil {
+		log.Fatal("Can't write Go file: ", err)
+	}
+
+	// Templates don't have to get the layout right, gofmt does.
+	src, err := format.Source(out.Files[file])
+	if err != nil {
+		log.Fatalf("Generated Go for %s does not parse: %v", file, err)
+	}
+	out.Files[file] = src
+}
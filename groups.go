@@ -0,0 +1,105 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// syscallGroups puts syscalls into broad categories by name. The first
+// group with a matching glob wins, so the more specific groups go first.
+var syscallGroups = []struct {
+	Name     string
+	Patterns []string
+}{
+	{"security", []string{"mac_*", "audit*", "getaudit*", "setaudit*", "getauid", "setauid", "cap_*",
+		"rctl_*", "csops*", "csrctl", "getloginclass", "setloginclass", "persona", "identitysvc", "kas_info"}},
+	{"net", []string{"accept*", "bind*", "connect*", "listen", "socket*", "send*", "recv*", "shutdown",
+		"getpeername", "getsockname", "getsockopt", "setsockopt", "sctp_*", "peeloff", "disconnectx",
+		"necp_*", "netagent_trigger", "setfib"}},
+	{"fs", []string{"mount", "unmount", "nmount", "statfs*", "fstatfs*", "getfsstat*", "quota*", "fsctl",
+		"ffsctl", "fs_snapshot", "vfs_purge", "nfs*", "swapon", "swapoff", "extattrctl"}},
+	{"ipc", []string{"msg*", "sem*", "shm*", "ksem_*", "kmq_*", "psynch_*", "ulock_*", "old_semwait_signal",
+		"pipe", "pipe2", "_umtx_op", "mkfifo*"}},
+	{"memory", []string{"mmap", "munmap", "mprotect", "madvise", "mincore", "minherit", "mlock*", "munlock*",
+		"msync", "obreak", "sbrk", "sstk", "mremap_encrypted", "shared_region_*", "ovadvise", "vm_pressure_monitor"}},
+	{"signal", []string{"sig*", "kill", "killpg", "pthread_kill", "pthread_sigmask", "pthread_markcancel",
+		"pthread_canceled", "disable_threadsignal", "pdkill", "thr_kill*"}},
+	{"time", []string{"gettimeofday", "settimeofday", "clock_*", "nanosleep", "adjtime", "ntp_*", "ffclock_*",
+		"ktimer_*", "getitimer", "setitimer"}},
+	{"event", []string{"kqueue", "kevent*", "poll", "ppoll", "select", "pselect", "openbsd_poll", "waitevent",
+		"watchevent", "modwatch"}},
+	{"process", []string{"fork", "vfork", "rfork", "pdfork", "execve", "fexecve", "mac_execve", "posix_spawn",
+		"exit", "sys_exit", "wait*", "getpid", "getppid", "getpgid", "getpgrp", "setpgid", "getsid", "setsid",
+		"*uid", "*gid", "getgroups", "setgroups", "initgroups", "getsgroups", "setsgroups", "getwgroups",
+		"setwgroups", "*priority", "getrlimit", "setrlimit", "getrusage", "thr_*", "bsdthread_*", "workq_*",
+		"pdgetpid", "ptrace", "procctl", "proc_*", "process_policy", "pid_*", "getcontext", "setcontext",
+		"swapcontext", "rtprio*", "gettid", "settid*", "issetugid", "setugid", "setprivexec", "setlogin",
+		"getlogin", "chroot", "jail*", "cpuset*", "numa_*", "yield", "abort*", "terminate_with_payload",
+		"coalition*", "ledger", "sched_*", "thread_selfid", "iopolicysys", "thread_selfusage", "work_interval_ctl", "memorystatus_*"}},
+	{"file", []string{"open*", "close*", "read*", "write*", "pread*", "pwrite*", "lseek", "dup*", "fcntl*",
+		"ioctl", "flock", "stat*", "lstat*", "fstat*", "nstat", "nlstat", "link*", "unlink*", "symlink*",
+		"rename*", "mkdir*", "rmdir", "mknod*", "chmod*", "fchmod*", "lchmod", "chown*", "fchown*", "lchown",
+		"chdir", "fchdir", "pthread_chdir", "pthread_fchdir", "getcwd", "access*", "faccessat", "eaccess",
+		"truncate", "ftruncate", "fsync*", "fdatasync", "sync", "utime*", "futime*", "lutimes", "*xattr",
+		"extattr_*", "acl_*", "*attrlist*", "getdirentries*", "getdents", "chflags*", "fchflags", "lchflags",
+		"creat", "pathconf", "fpathconf", "lpathconf", "umask*", "aio_*", "lio_listio", "posix_fadvise",
+		"posix_fallocate", "posix_openpt", "copyfile", "clonefileat", "fclonefileat", "exchangedata", "searchfs",
+		"fhopen", "fhstat*", "getfh", "lgetfh", "undelete", "revoke", "fsgetpath", "openbyid_np", "guarded_*",
+		"fileport_*", "change_fdguard_np", "delete"}},
+	{"system", []string{"sysctl*", "reboot", "kld*", "mod*", "kenv", "uname", "gethostname", "sethostname",
+		"getdomainname", "setdomainname", "gethostid", "sethostid", "gethostuuid", "getpagesize",
+		"getdtablesize", "getentropy", "uuidgen", "acct", "profil", "ktrace", "utrace", "kdebug_*", "sysarch",
+		"getkerninfo", "microstackshot", "stack_snapshot_with_config", "telemetry", "system_override",
+		"sfi_*", "usrctl"}},
+}
+
+// The group of syscalls that didn't match any other group.
+const miscGroup = "misc"
+
+// syscallGroup returns the category of a syscall, like net or file.
+func syscallGroup(name string) string {
+	// Variants like __mac_execve and read_nocancel belong with the
+	// syscall they wrap.
+	name = strings.TrimPrefix(strings.TrimSpace(name), "__")
+	name = strings.TrimSuffix(name, "_nocancel")
+
+	for _, group := range syscallGroups {
+		for _, pattern := range group.Patterns {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return group.Name
+			}
+		}
+	}
+
+	return miscGroup
+}
+
+// isGroup reports whether name is one of the syscall groups.
+func isGroup(name string) bool {
+	if name == miscGroup {
+		return true
+	}
+
+	for _, group := range syscallGroups {
+		if group.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
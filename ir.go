@@ -0,0 +1,224 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// schemaVersion is bumped whenever the JSON model changes in a way older
+// readers can't handle.
+const schemaVersion = 1
+
+// ModelJSON is the JSON form of a parsed master file. Every numbered
+// line is an entry, the ones that aren't on have no return type or params.
+type ModelJSON struct {
+	Schema   int         `json:"schema"`
+	Platform string      `json:"platform"`
+	Arch     string      `json:"arch"`
+	Entries  []EntryJSON `json:"entries"`
+
+	FlagSets map[string][]string `json:"flag_sets,omitempty"`
+}
+
+type EntryJSON struct {
+	Number       int         `json:"number"`
+	Name         string      `json:"name"`
+	Status       string      `json:"status"`
+	ReturnType   string      `json:"return_type,omitempty"`
+	Group        string      `json:"group,omitempty"`
+	AuditEvent   string      `json:"audit_event,omitempty"`
+	Conditionals []string    `json:"conditionals,omitempty"`
+	Class        string      `json:"class,omitempty"`
+	Comment      string      `json:"comment,omitempty"`
+	Described    bool        `json:"described,omitempty"`
+	Params       []ParamJSON `json:"params,omitempty"`
+}
+
+type ParamJSON struct {
+	Name      string `json:"name"`
+	CType     string `json:"c_type"`
+	Kind      string `json:"kind"`
+	Direction string `json:"direction"`
+	ArgType   string `json:"arg_type"`
+	GetArg    string `json:"get_arg"`
+	SyzType   string `json:"syz_type,omitempty"`
+	Flags     string `json:"flags,omitempty"`
+	LenOf     string `json:"len_of,omitempty"`
+}
+
+// exportModel converts the model to its JSON form.
+func exportModel(model *Model) ([]byte, error) {
+	m := ModelJSON{Schema: schemaVersion,
+		Platform: model.Platform.Name,
+		Arch:     model.Platform.Arch,
+		Entries:  []EntryJSON{},
+		FlagSets: model.FlagSets}
+
+	entries := model.slotEntries()
+	for i, slot := range model.Slots {
+		e := EntryJSON{Number: slot.Number,
+			Name:         slot.Name,
+			Status:       slot.Status,
+			AuditEvent:   slot.AuditEvent,
+			Conditionals: slot.Conditionals,
+			Class:        slot.Class,
+			Comment:      slot.Comment}
+
+		if entry := entries[i]; entry != nil {
+			e.ReturnType = entry.ReturnType
+			e.Group = entry.Group
+			e.Described = entry.Described
+			for _, arg := range entry.ArgArray {
+				e.Params = append(e.Params, ParamJSON{Name: arg.ArgName,
+					CType:     arg.CType,
+					Kind:      arg.Kind,
+					Direction: arg.Direction,
+					ArgType:   arg.ArgType,
+					GetArg:    arg.GetArg,
+					SyzType:   arg.SyzType,
+					Flags:     arg.Flags,
+					LenOf:     arg.LenOf})
+			}
+		}
+
+		m.Entries = append(m.Entries, e)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// importModel reads a model written by exportModel, the filter is applied
+// on top of the statuses in the file.
+func importModel(data []byte, filter Filter) (*Model, error) {
+	var m ModelJSON
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	if m.Schema != schemaVersion {
+		return nil, fmt.Errorf("Unsupported schema version %d, expected %d", m.Schema, schemaVersion)
+	}
+
+	if m.Platform == "" {
+		return nil, fmt.Errorf("No platform in the JSON model")
+	}
+
+	model := &Model{Platform: newPlatform(m.Platform, m.Arch), FlagSets: m.FlagSets}
+
+	// Params edited by hand may only give the C type, the rest is mapped
+	// from it like a master file's would be.
+	var mappings map[string]TypeMapping
+	if backend, err := lookupBackend(m.Platform); err == nil {
+		mappings = backend.Types
+	}
+
+	for _, e := range m.Entries {
+		switch e.Status {
+		case statusOn, statusNosys, statusObsolete, statusFiltered:
+		default:
+			return nil, fmt.Errorf("%d %s: unknown status %q", e.Number, e.Name, e.Status)
+		}
+
+		group := e.Group
+		if group == "" {
+			group = syscallGroup(e.Name)
+		}
+
+		slot := Slot{Number: e.Number,
+			Name:         e.Name,
+			Status:       e.Status,
+			AuditEvent:   e.AuditEvent,
+			Conditionals: e.Conditionals,
+			Class:        e.Class,
+			Comment:      e.Comment}
+
+		if slot.Status == statusOn && filter.match(e.Name, e.Number, group) != true {
+			slot.Status = statusFiltered
+		}
+
+		model.Slots = append(model.Slots, slot)
+		if slot.Status != statusOn {
+			continue
+		}
+
+		if e.Name == "" {
+			return nil, fmt.Errorf("%d: syscall that is on has no name", e.Number)
+		}
+
+		if len(e.Params) > len(argSymbols) {
+			return nil, fmt.Errorf("%s: more than %d params", e.Name, len(argSymbols))
+		}
+
+		entry := Entry{EntryNumber: strconv.Itoa(e.Number),
+			TotalArgs:    strconv.Itoa(len(e.Params)),
+			ReturnType:   e.ReturnType,
+			SyscallName:  e.Name,
+			Status:       statusOn,
+			Group:        group,
+			AuditEvent:   e.AuditEvent,
+			Conditionals: e.Conditionals,
+			Described:    e.Described}
+
+		for i, p := range e.Params {
+			arg := Arg{ArgType: p.ArgType,
+				GetArg:    p.GetArg,
+				ArgSymbol: argSymbols[i],
+				CType:     p.CType,
+				ArgName:   p.Name,
+				Kind:      p.Kind,
+				Direction: p.Direction,
+				SyzType:   p.SyzType,
+				Flags:     p.Flags,
+				LenOf:     p.LenOf}
+
+			if arg.ArgType == "" || arg.GetArg == "" {
+				types, args := mapTypes([]string{strings.TrimSpace(p.CType + " " + p.Name)}, mappings)
+				if arg.ArgType == "" {
+					arg.ArgType = types[0]
+				}
+				if arg.GetArg == "" {
+					arg.GetArg = args[0]
+				}
+			}
+			if arg.ArgType == "" || arg.GetArg == "" {
+				return nil, fmt.Errorf("%s: param %d has no arg_type or get_arg and c_type %q has no mapping",
+					e.Name, i+1, p.CType)
+			}
+
+			if arg.Kind == "" {
+				arg.Kind = argKind(arg.CType)
+			}
+			if arg.Direction == "" {
+				arg.Direction = argDirection(arg.CType)
+			}
+
+			entry.ArgArray = append(entry.ArgArray, arg)
+		}
+
+		model.Entries = append(model.Entries, entry)
+	}
+
+	return model, nil
+}
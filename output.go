@@ -0,0 +1,268 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// A manifest lists every file entrygen generated in the output directory,
+// so files for syscalls that were removed can be deleted on the next run.
+// Each format and platform has its own, ie .entrygen-manifest-c-freebsd,
+// so outputs sharing a directory don't delete each other's files.
+const manifestName = ".entrygen-manifest"
+
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// Output holds the generated files in memory until they are written, so
+// files whose content didn't change can be left alone.
+type Output struct {
+	Dir      string
+	Manifest string            // The name of the manifest in Dir.
+	Files    map[string][]byte // File contents keyed by the name relative to Dir.
+}
+
+// FileChange is what writing the output does to a single file.
+type FileChange struct {
+	Name   string
+	Action string // One of create, update or delete.
+	Old    []byte // The content on disk, nil for created files.
+	New    []byte // The generated content, nil for deleted files.
+}
+
+func newOutput(dir string, format string, platform string) *Output {
+	return &Output{Dir: dir,
+		Manifest: manifestName + "-" + format + "-" + platform,
+		Files:    make(map[string][]byte)}
+}
+
+// path returns where the file called name lives on disk.
+func (o *Output) path(name string) string {
+	return filepath.Join(o.Dir, filepath.FromSlash(name))
+}
+
+// execute renders the template and adds the result as the file called name.
+func (o *Output) execute(name string, t *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	o.Files[name] = buf.Bytes()
+	return nil
+}
+
+// names returns the name of every generated file in sorted order.
+func (o *Output) names() []string {
+	var names []string
+	for name := range o.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// manifest returns the content of the manifest for the generated files.
+func (o *Output) manifest() []byte {
+	var buf bytes.Buffer
+	for _, name := range o.names() {
+		buf.WriteString(name + "\n")
+	}
+
+	return buf.Bytes()
+}
+
+// readManifest returns the files listed in the manifest, ignoring anything
+// that would point outside of the output directory.
+func (o *Output) readManifest() ([]string, error) {
+	f, err := os.Open(o.path(o.Manifest))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, manifestName) {
+			continue
+		}
+
+		clean := filepath.ToSlash(filepath.Clean(name))
+		if filepath.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+			continue
+		}
+		names = append(names, clean)
+	}
+
+	return names, scanner.Err()
+}
+
+// changes compares the generated files with the files on disk. Files that
+// the previous run generated but this one didn't are deleted.
+func (o *Output) changes() ([]FileChange, error) {
+	files := make(map[string][]byte)
+	for name, data := range o.Files {
+		files[name] = data
+	}
+	files[o.Manifest] = o.manifest()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []FileChange
+	for _, name := range names {
+		old, err := ioutil.ReadFile(o.path(name))
+		if os.IsNotExist(err) {
+			changes = append(changes, FileChange{Name: name, Action: actionCreate, New: files[name]})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(old, files[name]) != true {
+			changes = append(changes, FileChange{Name: name, Action: actionUpdate, Old: old, New: files[name]})
+		}
+	}
+
+	previous, err := o.readManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range previous {
+		if _, ok := files[name]; ok {
+			continue
+		}
+
+		old, err := ioutil.ReadFile(o.path(name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, FileChange{Name: name, Action: actionDelete, Old: old})
+	}
+
+	return changes, nil
+}
+
+// apply makes the changes on disk. New content is written to a temporary
+// file first and renamed into place, so a file is never half written.
+func (o *Output) apply(changes []FileChange) error {
+	if err := createOutputDir(o.Dir); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		var err error
+		switch change.Action {
+		case actionCreate, actionUpdate:
+			err = writeFileAtomic(o.path(change.Name), change.New)
+		case actionDelete:
+			err = os.Remove(o.path(change.Name))
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write writes every file that changed and deletes stale ones.
+func (o *Output) write() error {
+	changes, err := o.changes()
+	if err != nil {
+		return err
+	}
+
+	return o.apply(changes)
+}
+
+// diff writes a unified diff of every change to w.
+func (o *Output) diff(w io.Writer, changes []FileChange) {
+	for _, change := range changes {
+		file := filepath.ToSlash(o.path(change.Name))
+		oldName, newName := "a/"+file, "b/"+file
+
+		switch change.Action {
+		case actionCreate:
+			oldName = "/dev/null"
+		case actionDelete:
+			newName = "/dev/null"
+		}
+
+		fmt.Fprint(w, unifiedDiff(oldName, newName, change.Old, change.New))
+	}
+}
+
+func writeFileAtomic(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	// Clean up the temporary file if anything goes wrong.
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
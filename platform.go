@@ -0,0 +1,198 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// TypeMapping says how the fuzzer generates an argument of a C type.
+type TypeMapping struct {
+	ArgType string
+	GetArg  string
+}
+
+// Backend is an operating system entrygen can generate entries for.
+type Backend struct {
+	Name    string
+	Display string
+	Aliases []string
+
+	// Dialect is the syntax of the backend's master files.
+	Dialect string
+
+	// Input is the embedded master file used when no -input is given.
+	Input string
+
+	// Types maps C types only this operating system uses, they are
+	// checked before the shared type mappings.
+	Types map[string]TypeMapping
+}
+
+var (
+	mapInt = TypeMapping{"INT", "&generate_int"}
+	mapPtr = TypeMapping{"ADDRESS", "&generate_ptr"}
+)
+
+var backends = []Backend{
+	{Name: "freebsd",
+		Display: "FreeBSD",
+		Dialect: "freebsd",
+		Input:   "freebsd-syscall.master",
+		Types: map[string]TypeMapping{
+			"__socklen_t":                           mapInt,
+			"acl_type_t":                            mapInt,
+			"clockid_t":                             mapInt,
+			"cpulevel_t":                            mapInt,
+			"cpusetid_t":                            mapInt,
+			"cpuwhich_t":                            mapInt,
+			"dev_t":                                 mapInt,
+			"lwpid_t":                               mapInt,
+			"mode_t":                                mapInt,
+			"osigset_t":                             mapInt,
+			"semid_t":                               mapInt,
+			"__socklen_t *":                         mapPtr,
+			"cap_rights_t *":                        mapPtr,
+			"clockid_t *":                           mapPtr,
+			"const cpuset_t *":                      mapPtr,
+			"const sigset_t *":                      mapPtr,
+			"const struct __ucontext *":             mapPtr,
+			"const struct itimerspec *":             mapPtr,
+			"const struct mq_attr *":                mapPtr,
+			"const struct sched_param *":            mapPtr,
+			"const struct sigaction *":              mapPtr,
+			"const struct sigevent *":               mapPtr,
+			"const struct ucontext4 *":              mapPtr,
+			"const struct vm_domain_policy_entry *": mapPtr,
+			"const u_long *":                        mapPtr,
+			"const void *":                          mapPtr,
+			"cpuset_t *":                            mapPtr,
+			"cpusetid_t *":                          mapPtr,
+			"fd_set *":                              mapPtr,
+			"ffcounter *":                           mapPtr,
+			"semid_t *":                             mapPtr,
+			"sigset_t *":                            mapPtr,
+			"stack_t *":                             mapPtr,
+			"struct __ucontext *":                   mapPtr,
+			"struct __wrusage *":                    mapPtr,
+			"struct acl *":                          mapPtr,
+			"struct aiocb *":                        mapPtr,
+			"struct auditinfo *":                    mapPtr,
+			"struct ffclock_estimate *":             mapPtr,
+			"struct fhandle *":                      mapPtr,
+			"struct itimerspec *":                   mapPtr,
+			"struct jail *":                         mapPtr,
+			"struct kld_file_stat*":                 mapPtr,
+			"struct module_stat *":                  mapPtr,
+			"struct mq_attr *":                      mapPtr,
+			"struct msqid_ds *":                     mapPtr,
+			"struct msqid_ds_old *":                 mapPtr,
+			"struct nstat *":                        mapPtr,
+			"struct ntptimeval *":                   mapPtr,
+			"struct oaiocb *":                       mapPtr,
+			"struct omsghdr *":                      mapPtr,
+			"struct orlimit *":                      mapPtr,
+			"struct osigaction *":                   mapPtr,
+			"struct osigcontext *":                  mapPtr,
+			"struct osigevent *":                    mapPtr,
+			"struct ostat *":                        mapPtr,
+			"struct ostatfs *":                      mapPtr,
+			"struct rtprio *":                       mapPtr,
+			"struct sched_param *":                  mapPtr,
+			"struct sctp_sndrcvinfo *":              mapPtr,
+			"struct shmid_ds_old *":                 mapPtr,
+			"struct sigevent *":                     mapPtr,
+			"struct sigstack *":                     mapPtr,
+			"struct stat *":                         mapPtr,
+			"struct thr_param *":                    mapPtr,
+			"struct timespec *":                     mapPtr,
+			"struct timex *":                        mapPtr,
+			"struct utsname *":                      mapPtr,
+			"struct uuid *":                         mapPtr,
+			"struct vm_domain_policy_entry *":       mapPtr,
+			"u_int *":                               mapPtr,
+			"ucontext_t *":                          mapPtr,
+			"union semun *":                         mapPtr,
+			"union semun_old *":                     mapPtr,
+			"unsigned *":                            mapPtr}},
+	{Name: "darwin",
+		Display: "macOS",
+		Aliases: []string{"macos", "osx", "xnu"},
+		Dialect: "xnu",
+		Input:   "osx-syscall.master"},
+}
+
+// lookupBackend returns the backend called name or one of its aliases.
+func lookupBackend(name string) (Backend, error) {
+	for _, b := range backends {
+		if strings.EqualFold(b.Name, name) {
+			return b, nil
+		}
+
+		for _, alias := range b.Aliases {
+			if strings.EqualFold(alias, name) {
+				return b, nil
+			}
+		}
+	}
+
+	var names []string
+	for _, b := range backends {
+		names = append(names, b.Name)
+	}
+
+	return Backend{}, fmt.Errorf("Unknown operating system %q, expected one of %s. Run with -os help for details",
+		name, strings.Join(names, ", "))
+}
+
+// printBackends lists every backend for -os help.
+func printBackends(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OS\tNAME\tALIASES\tDIALECT\tINPUT")
+
+	for _, b := range backends {
+		aliases := strings.Join(b.Aliases, ",")
+		if aliases == "" {
+			aliases = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Name, b.Display, aliases, b.Dialect, b.Input)
+	}
+
+	tw.Flush()
+}
+
+// mapTypes picks the type and get argument function of every parameter,
+// the backend's own mappings win over the shared ones.
+func mapTypes(params []string, mappings map[string]TypeMapping) ([]string, []string) {
+	types := make([]string, len(params))
+	args := make([]string, len(params))
+
+	for i, param := range params {
+		if m, ok := mappings[removeArgName(param)]; ok {
+			types[i] = m.ArgType
+			args[i] = m.GetArg
+			continue
+		}
+
+		args[i] = generateGetArgFunction([]string{param})[0]
+		types[i] = generateGetType([]string{param})[0]
+	}
+
+	return types, args
+}
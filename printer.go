@@ -0,0 +1,152 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// Printers is the data for the argument pretty-printer templates.
+type Printers struct {
+	Year     string
+	Platform Platform
+	Header   string
+	Flags    []PrinterFlags
+	Entries  []PrinterEntry
+}
+
+// PrinterFlags is a table of flag names a flags argument is printed with.
+type PrinterFlags struct {
+	Ident  string
+	Values []string
+}
+
+// PrinterEntry is the printer function of one syscall.
+type PrinterEntry struct {
+	Name   string
+	Ident  string // The name of the printer function, ie print_entry_open.
+	Number string
+	Args   []PrinterArg
+	Skip
+}
+
+// PrinterArg says how one argument is printed.
+type PrinterArg struct {
+	Symbol  string // The index of the argument, ie FIRST_ARG.
+	Printer string // One of fd, string, flags, hex, pointer, octal, int, long or unsigned.
+	Flags   string // The flag table of a flags argument.
+}
+
+// Argument names that mean the int holds flags.
+var printerFlagNames = map[string]bool{"flags": true, "oflag": true, "options": true, "prot": true}
+
+// printerFor picks how an argument is printed from its kind. Without -syz
+// pointers are guessed to be strings and ints to be flags by name and
+// type, flags without a known flag set are printed in hex.
+func printerFor(arg Arg, flags map[string][]string) string {
+	switch arg.Kind {
+	case "fd", "pid":
+		return "fd"
+	case "path", "string":
+		return "string"
+	case "pointer":
+		if guessString(arg) {
+			return "string"
+		}
+		return "pointer"
+	case "len":
+		return "unsigned"
+	case "flags":
+		if _, ok := flags[arg.Flags]; ok {
+			return "flags"
+		}
+		return "hex"
+	case "port":
+		return "unsigned"
+	}
+
+	switch {
+	case arg.CType == "mode_t" || arg.ArgName == "mode":
+		return "octal"
+	case printerFlagNames[arg.ArgName]:
+		return "hex"
+	case syzFdNames[arg.ArgName] && syzIntType(arg.CType) == "int32":
+		return "fd"
+	case syzIntType(arg.CType) == "int32":
+		return "int"
+	}
+
+	return "long"
+}
+
+// buildPrinters converts the model to printer functions.
+func buildPrinters(model *Model) Printers {
+	p := Printers{Platform: model.Platform}
+	skips := model.skips(len(argSymbols))
+	tables := make(map[string]bool)
+
+	for i, entry := range model.Entries {
+		name := strings.TrimSpace(entry.SyscallName)
+		e := PrinterEntry{Name: name,
+			Ident:  "print_entry_" + cIdentifier(name),
+			Number: entry.EntryNumber}
+
+		if e.Skipped = skips[i]; e.Skipped != "" {
+			p.Entries = append(p.Entries, e)
+			continue
+		}
+
+		for _, arg := range entry.ArgArray {
+			a := PrinterArg{Symbol: arg.ArgSymbol, Printer: printerFor(arg, model.FlagSets)}
+			if a.Printer == "flags" {
+				a.Flags = cIdentifier(arg.Flags) + "_names"
+				if tables[a.Flags] != true {
+					tables[a.Flags] = true
+					p.Flags = append(p.Flags, PrinterFlags{Ident: a.Flags, Values: model.FlagSets[arg.Flags]})
+				}
+			}
+			e.Args = append(e.Args, a)
+		}
+
+		p.Entries = append(p.Entries, e)
+	}
+
+	return p
+}
+
+// renderPrinters generates a C printer function for every syscall and a
+// header declaring them.
+func renderPrinters(out *Output, model *Model, opts Options) {
+	p := buildPrinters(model)
+	p.Header = "print_" + model.Platform.Name + ".h"
+
+	files := []struct{ name, template string }{
+		{"print_" + model.Platform.Name + ".c", "printer.txt"},
+		{p.Header, "printer_header.txt"}}
+
+	for _, f := range files {
+		t, err := loadTemplate(opts.TemplateDir, model, f.template)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		p.Year = copyrightYear(out.path(f.name), opts)
+		if err = out.execute(f.name, t, p); err != nil {
+			log.Fatal("Can't write printer file: ", err)
+		}
+	}
+}
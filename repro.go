@@ -0,0 +1,98 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// reproMaxArgs is the number of arguments every reproducer helper takes.
+const reproMaxArgs = 6
+
+// Repro is the data for the reproducer templates.
+type Repro struct {
+	Year     string
+	Platform Platform
+	Header   string
+	Params   []string // The parameters every helper takes, a1 to a6.
+	Entries  []ReproEntry
+}
+
+// ReproEntry is the reproducer helper of one syscall.
+type ReproEntry struct {
+	Name   string
+	Ident  string // The name of the helper, ie repro_call_open.
+	Number string
+	Args   []string // The parameters passed to the syscall.
+	Unused []string // The parameters the syscall doesn't take.
+	Skip
+}
+
+// buildRepro converts the model to reproducer helpers. Syscalls with more
+// than six arguments are left out.
+func buildRepro(model *Model) Repro {
+	r := Repro{Platform: model.Platform}
+	skips := model.skips(reproMaxArgs)
+
+	for i := 1; i <= reproMaxArgs; i++ {
+		r.Params = append(r.Params, fmt.Sprintf("a%d", i))
+	}
+
+	for i, entry := range model.Entries {
+		name := strings.TrimSpace(entry.SyscallName)
+		e := ReproEntry{Name: name,
+			Ident:  "repro_call_" + cIdentifier(name),
+			Number: entry.EntryNumber}
+
+		if e.Skipped = skips[i]; e.Skipped != "" {
+			r.Entries = append(r.Entries, e)
+			continue
+		}
+
+		e.Args = r.Params[:len(entry.ArgArray)]
+		e.Unused = r.Params[len(entry.ArgArray):]
+		r.Entries = append(r.Entries, e)
+	}
+
+	return r
+}
+
+// renderRepro generates a reproducer helper for every syscall, the table
+// that replays logged calls through them and a skeleton program to paste
+// a fuzzer log into.
+func renderRepro(out *Output, model *Model, opts Options) {
+	r := buildRepro(model)
+	r.Header = "repro_" + model.Platform.Name + ".h"
+
+	files := []struct{ name, template string }{
+		{"repro_" + model.Platform.Name + ".c", "repro.txt"},
+		{r.Header, "repro_header.txt"},
+		{"repro_main_" + model.Platform.Name + ".c", "repro_main.txt"}}
+
+	for _, f := range files {
+		t, err := loadTemplate(opts.TemplateDir, model, f.template)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		r.Year = copyrightYear(out.path(f.name), opts)
+		if err = out.execute(f.name, t, r); err != nil {
+			log.Fatal("Can't write reproducer file: ", err)
+		}
+	}
+}
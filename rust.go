@@ -0,0 +1,126 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rust is the data for the Rust syscall table template.
+type Rust struct {
+	Platform Platform
+	ArgTypes []string // Variants of the ArgType enum, one per C type mapping.
+	Consts   []RustConst
+	Entries  []RustEntry
+}
+
+type RustConst struct {
+	Name  string
+	Value string
+}
+
+type RustEntry struct {
+	Name       string // Quoted Rust string literals from here on.
+	Number     string
+	ReturnType string
+	Group      string
+	Args       []RustArg
+}
+
+type RustArg struct {
+	Name      string
+	CType     string
+	Kind      string // Variant of the ArgKind enum.
+	Direction string // Variant of the Direction enum.
+	ArgType   string // Variant of the ArgType enum.
+}
+
+// rustVariant turns a kind, direction or C type mapping into an enum
+// variant, ie ADDRESS to Address and inout to InOut.
+func rustVariant(str string) string {
+	switch str {
+	case "":
+		return "Unknown"
+	case "inout":
+		return "InOut"
+	}
+
+	return goName(strings.ToLower(str))
+}
+
+// rustString quotes str as a Rust string literal.
+func rustString(str string) string {
+	return strconv.Quote(str)
+}
+
+// buildRust converts the model to the Rust syscall table. The ArgType
+// enum has a variant for every C type mapping the entries use, so it
+// matches the arg_type_array of the C entries.
+func buildRust(model *Model) Rust {
+	r := Rust{Platform: model.Platform}
+	types := map[string]bool{"Unknown": true}
+	redefined := model.redefinitions()
+
+	for i, entry := range model.Entries {
+		name := strings.TrimSpace(entry.SyscallName)
+		e := RustEntry{Name: rustString(name),
+			Number:     entry.EntryNumber,
+			ReturnType: rustString(entry.ReturnType),
+			Group:      rustString(entry.Group)}
+
+		for _, arg := range entry.ArgArray {
+			a := RustArg{Name: rustString(arg.ArgName),
+				CType:     rustString(arg.CType),
+				Kind:      rustVariant(arg.Kind),
+				Direction: rustVariant(arg.Direction),
+				ArgType:   rustVariant(arg.ArgType)}
+
+			types[a.ArgType] = true
+			e.Args = append(e.Args, a)
+		}
+
+		r.Entries = append(r.Entries, e)
+
+		// Syscalls defined twice are in the table twice, but only the
+		// definition the other outputs keep gets a constant.
+		if redefined[i] == "" {
+			r.Consts = append(r.Consts, RustConst{Name: "SYS_" + strings.ToUpper(cIdentifier(name)),
+				Value: entry.EntryNumber})
+		}
+	}
+
+	for t := range types {
+		r.ArgTypes = append(r.ArgTypes, t)
+	}
+	sort.Strings(r.ArgTypes)
+
+	return r
+}
+
+// renderRust generates a Rust module with the syscall table and numbers.
+func renderRust(out *Output, model *Model, opts Options) {
+	t, err := loadTemplate(opts.TemplateDir, model, "rust.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = out.execute(amalgamationName(model.Platform, ".rs"), t, buildRust(model)); err != nil {
+		log.Fatal("Can't write Rust file: ", err)
+	}
+}
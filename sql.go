@@ -0,0 +1,209 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// sqlSchema creates the tables of the SQL export. It drops them first so
+// the script can be loaded into the same database again.
+const sqlSchema = `DROP TABLE IF EXISTS params;
+DROP TABLE IF EXISTS types;
+DROP TABLE IF EXISTS syscalls;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS platforms;
+
+CREATE TABLE platforms (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    display TEXT NOT NULL,
+    arch TEXT NOT NULL
+);
+
+CREATE TABLE groups (
+    name TEXT PRIMARY KEY
+);
+
+CREATE TABLE syscalls (
+    id INTEGER PRIMARY KEY,
+    platform_id INTEGER NOT NULL REFERENCES platforms(id),
+    number INTEGER NOT NULL,
+    name TEXT,
+    status TEXT NOT NULL,
+    return_type TEXT,
+    group_name TEXT REFERENCES groups(name),
+    audit_event TEXT,
+    class TEXT,
+    comment TEXT,
+    conditionals TEXT,
+    described INTEGER NOT NULL
+);
+
+CREATE TABLE types (
+    id INTEGER PRIMARY KEY,
+    platform_id INTEGER NOT NULL REFERENCES platforms(id),
+    c_type TEXT,
+    arg_type TEXT,
+    get_arg TEXT,
+    UNIQUE (platform_id, c_type)
+);
+
+CREATE TABLE params (
+    syscall_id INTEGER NOT NULL REFERENCES syscalls(id),
+    position INTEGER NOT NULL,
+    name TEXT,
+    type_id INTEGER NOT NULL REFERENCES types(id),
+    kind TEXT NOT NULL,
+    direction TEXT NOT NULL,
+    syz_type TEXT,
+    flags TEXT,
+    len_of TEXT,
+    PRIMARY KEY (syscall_id, position)
+);
+
+CREATE INDEX syscalls_name ON syscalls(name);
+`
+
+// sqlTable is a table of the SQL export. Its values are int64, string or
+// nil for NULL, in the order of Columns. A column named id is the table's
+// INTEGER PRIMARY KEY.
+type sqlTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// sqlText is a string value, an empty string is NULL.
+func sqlText(str string) interface{} {
+	if str == "" {
+		return nil
+	}
+
+	return str
+}
+
+// sqlValue writes a value as a SQL literal.
+func sqlValue(value interface{}) string {
+	switch v := value.(type) {
+	case int64:
+		return fmt.Sprint(v)
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	}
+
+	return "NULL"
+}
+
+// sqlTables converts the models of one or more platforms to the rows of
+// the tables in sqlSchema, in the order they are created.
+func sqlTables(models []*Model) ([]sqlTable, error) {
+	platforms := sqlTable{Name: "platforms", Columns: []string{"id", "name", "display", "arch"}}
+	groups := sqlTable{Name: "groups", Columns: []string{"name"}}
+	syscalls := sqlTable{Name: "syscalls", Columns: []string{"id", "platform_id", "number", "name", "status",
+		"return_type", "group_name", "audit_event", "class", "comment", "conditionals", "described"}}
+	types := sqlTable{Name: "types", Columns: []string{"id", "platform_id", "c_type", "arg_type", "get_arg"}}
+	params := sqlTable{Name: "params", Columns: []string{"syscall_id", "position", "name", "type_id", "kind",
+		"direction", "syz_type", "flags", "len_of"}}
+
+	for _, group := range syscallGroups {
+		groups.Rows = append(groups.Rows, []interface{}{group.Name})
+	}
+	groups.Rows = append(groups.Rows, []interface{}{miscGroup})
+
+	exported := make(map[string]bool)
+	var syscallID, typeID int64
+	for i, model := range models {
+		platformID := int64(i + 1)
+		if exported[model.Platform.Name] {
+			return nil, fmt.Errorf("%s is exported more than once", model.Platform.Name)
+		}
+		exported[model.Platform.Name] = true
+
+		platforms.Rows = append(platforms.Rows, []interface{}{platformID, sqlText(model.Platform.Name),
+			sqlText(model.Platform.Display), sqlText(model.Platform.Arch)})
+
+		typeIDs := make(map[string]int64)
+
+		entries := model.slotEntries()
+		for i, slot := range model.Slots {
+			syscallID++
+			entry := Entry{}
+			if entries[i] != nil {
+				entry = *entries[i]
+			}
+
+			described := int64(0)
+			if entry.Described {
+				described = 1
+			}
+
+			syscalls.Rows = append(syscalls.Rows, []interface{}{syscallID, platformID, int64(slot.Number),
+				sqlText(strings.TrimSpace(slot.Name)), sqlText(slot.Status), sqlText(entry.ReturnType),
+				sqlText(entry.Group), sqlText(slot.AuditEvent), sqlText(slot.Class), sqlText(slot.Comment),
+				sqlText(strings.Join(slot.Conditionals, " && ")), described})
+
+			for position, arg := range entry.ArgArray {
+				id, ok := typeIDs[arg.CType]
+				if ok != true {
+					typeID++
+					id = typeID
+					typeIDs[arg.CType] = id
+					types.Rows = append(types.Rows, []interface{}{id, platformID, sqlText(arg.CType),
+						sqlText(arg.ArgType), sqlText(arg.GetArg)})
+				}
+
+				params.Rows = append(params.Rows, []interface{}{syscallID, int64(position + 1),
+					sqlText(arg.ArgName), id, sqlText(arg.Kind), sqlText(arg.Direction),
+					sqlText(arg.SyzType), sqlText(arg.Flags), sqlText(arg.LenOf)})
+			}
+		}
+	}
+
+	return []sqlTable{platforms, groups, syscalls, types, params}, nil
+}
+
+// exportSQL converts the models of one or more platforms to a SQL script
+// that creates and fills a database, ie with sqlite3 entrygen.db < model.sql.
+func exportSQL(models []*Model) ([]byte, error) {
+	tables, err := sqlTables(models)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("-- Code generated by entrygen export. DO NOT EDIT.\n\n")
+	buffer.WriteString("BEGIN TRANSACTION;\n\n")
+	buffer.WriteString(sqlSchema)
+
+	for _, table := range tables {
+		buffer.WriteString("\n")
+		for _, row := range table.Rows {
+			values := make([]string, len(row))
+			for i, value := range row {
+				values[i] = sqlValue(value)
+			}
+			fmt.Fprintf(&buffer, "INSERT INTO %s VALUES (%s);\n", table.Name, strings.Join(values, ", "))
+		}
+	}
+
+	buffer.WriteString("\nCOMMIT;\n")
+
+	return buffer.Bytes(), nil
+}
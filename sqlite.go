@@ -0,0 +1,460 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The database is written in the SQLite file format described at
+// https://www.sqlite.org/fileformat2.html, so entrygen needs no SQLite
+// driver to build. Every table and index is a b-tree built bottom up from
+// its sorted rows.
+const sqlitePageSize = 4096
+
+// B-tree page types.
+const (
+	sqliteIndexInterior = 0x02
+	sqliteTableInterior = 0x05
+	sqliteIndexLeaf     = 0x0a
+	sqliteTableLeaf     = 0x0d
+)
+
+// sqliteIndex is an index SQLite keeps on a table of sqlSchema. UNIQUE and
+// PRIMARY KEY constraints other than INTEGER PRIMARY KEY get an automatic
+// index that has no SQL. The list has to follow the constraints and the
+// CREATE INDEX statements of sqlSchema.
+type sqliteIndex struct {
+	Name    string
+	Table   string
+	Columns []string
+}
+
+var sqliteIndexes = []sqliteIndex{
+	{"sqlite_autoindex_platforms_1", "platforms", []string{"name"}},
+	{"sqlite_autoindex_groups_1", "groups", []string{"name"}},
+	{"sqlite_autoindex_types_1", "types", []string{"platform_id", "c_type"}},
+	{"sqlite_autoindex_params_1", "params", []string{"syscall_id", "position"}},
+	{"syscalls_name", "syscalls", []string{"name"}},
+}
+
+// sqliteCell is a cell of a b-tree page. Body is the encoded cell of a
+// leaf page, interior pages prefix it with the left child page, except
+// table interior cells which only have the child and the rowid.
+type sqliteCell struct {
+	Child uint32
+	RowID int64
+	Body  []byte
+}
+
+// sqliteFile is a database being written, page 1 is pages[0].
+type sqliteFile struct {
+	pages [][]byte
+}
+
+// sqliteVarint encodes a SQLite variable length integer.
+func sqliteVarint(v uint64) []byte {
+	if v > 0x00ffffffffffffff {
+		buf := make([]byte, 9)
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return buf
+	}
+
+	buf := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		buf = append([]byte{byte(v&0x7f) | 0x80}, buf...)
+	}
+
+	return buf
+}
+
+// sqliteRecord encodes values in the record format.
+func sqliteRecord(values []interface{}) []byte {
+	var header, body []byte
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case int64:
+			switch {
+			case v == 0:
+				header = append(header, 8)
+			case v == 1:
+				header = append(header, 9)
+			default:
+				serial, size := uint64(6), 8
+				for i, n := range []int{1, 2, 3, 4, 6} {
+					if v >= -1<<(uint(n)*8-1) && v < 1<<(uint(n)*8-1) {
+						serial, size = uint64(i+1), n
+						break
+					}
+				}
+				header = append(header, sqliteVarint(serial)...)
+				var buf [8]byte
+				binary.BigEndian.PutUint64(buf[:], uint64(v))
+				body = append(body, buf[8-size:]...)
+			}
+		case string:
+			header = append(header, sqliteVarint(uint64(len(v))*2+13)...)
+			body = append(body, v...)
+		default:
+			header = append(header, 0)
+		}
+	}
+
+	size := len(header) + 1
+	for len(sqliteVarint(uint64(size))) != size-len(header) {
+		size = len(header) + len(sqliteVarint(uint64(size)))
+	}
+
+	record := append(sqliteVarint(uint64(size)), header...)
+	return append(record, body...)
+}
+
+// sqliteCompare orders index keys: NULL, then integers, then text compared
+// byte by byte.
+func sqliteCompare(a, b []interface{}) int {
+	rank := func(value interface{}) int {
+		switch value.(type) {
+		case int64:
+			return 1
+		case string:
+			return 2
+		}
+		return 0
+	}
+
+	for i := range a {
+		if ra, rb := rank(a[i]), rank(b[i]); ra != rb {
+			return ra - rb
+		}
+		switch v := a[i].(type) {
+		case int64:
+			if w := b[i].(int64); v != w {
+				if v < w {
+					return -1
+				}
+				return 1
+			}
+		case string:
+			if c := strings.Compare(v, b[i].(string)); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+// allocate adds an empty page and returns its number.
+func (f *sqliteFile) allocate() uint32 {
+	f.pages = append(f.pages, make([]byte, sqlitePageSize))
+	return uint32(len(f.pages))
+}
+
+// spill lays out a payload for a cell, the part that doesn't fit in the
+// cell is moved to a chain of overflow pages.
+func (f *sqliteFile) spill(payload []byte, index bool) []byte {
+	usable := sqlitePageSize
+	maxLocal := usable - 35
+	if index {
+		maxLocal = (usable-12)*64/255 - 23
+	}
+	if len(payload) <= maxLocal {
+		return payload
+	}
+
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+
+	cell := append([]byte{}, payload[:local]...)
+	var link []byte
+	for rest := payload[local:]; len(rest) > 0; {
+		page := f.allocate()
+		if link == nil {
+			cell = append(cell, 0, 0, 0, 0)
+			link = cell[len(cell)-4:]
+		}
+		binary.BigEndian.PutUint32(link, page)
+
+		n := copy(f.pages[page-1][4:], rest)
+		rest = rest[n:]
+		link = f.pages[page-1][:4]
+	}
+
+	return cell
+}
+
+// encode returns a cell as it's stored on a page of the given type.
+func (c sqliteCell) encode(kind byte) []byte {
+	var child [4]byte
+	binary.BigEndian.PutUint32(child[:], c.Child)
+
+	switch kind {
+	case sqliteTableInterior:
+		return append(child[:], sqliteVarint(uint64(c.RowID))...)
+	case sqliteIndexInterior:
+		return append(child[:], c.Body...)
+	}
+
+	return c.Body
+}
+
+// headerSize is the size of the b-tree page header of a page type.
+func headerSize(kind byte) int {
+	if kind == sqliteTableLeaf || kind == sqliteIndexLeaf {
+		return 8
+	}
+	return 12
+}
+
+// fill returns how many of the cells fit on a page, page 1 also holds the
+// database header.
+func fill(page uint32, kind byte, cells []sqliteCell) int {
+	free := sqlitePageSize - headerSize(kind)
+	if page == 1 {
+		free -= 100
+	}
+
+	for i, cell := range cells {
+		if free -= 2 + len(cell.encode(kind)); free < 0 {
+			return i
+		}
+	}
+
+	return len(cells)
+}
+
+// writePage writes cells to a b-tree page, right is the right-most child
+// of interior pages.
+func (f *sqliteFile) writePage(page uint32, kind byte, cells []sqliteCell, right uint32) {
+	buf := f.pages[page-1]
+	header := 0
+	if page == 1 {
+		header = 100
+	}
+
+	content := sqlitePageSize
+	for i, cell := range cells {
+		b := cell.encode(kind)
+		content -= len(b)
+		copy(buf[content:], b)
+		binary.BigEndian.PutUint16(buf[header+headerSize(kind)+2*i:], uint16(content))
+	}
+
+	buf[header] = kind
+	binary.BigEndian.PutUint16(buf[header+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(buf[header+5:], uint16(content))
+	if headerSize(kind) == 12 {
+		binary.BigEndian.PutUint32(buf[header+8:], right)
+	}
+}
+
+// writeTree writes the sorted leaf cells of a table or index as a b-tree
+// rooted at root. Each level is split over as many pages as it needs until
+// it fits on the root page. A table keeps its rows on the leaves and the
+// interior cells repeat the largest rowid under them, an index moves the
+// key between two pages up to their parent.
+func (f *sqliteFile) writeTree(root uint32, cells []sqliteCell, index bool) {
+	kind, interior := byte(sqliteTableLeaf), byte(sqliteTableInterior)
+	if index {
+		kind, interior = sqliteIndexLeaf, sqliteIndexInterior
+	}
+
+	var right uint32
+	for fill(root, kind, cells) < len(cells) {
+		var parents []sqliteCell
+		for len(cells) > 0 {
+			page := f.allocate()
+			n := fill(page, kind, cells)
+
+			if n == len(cells) {
+				f.writePage(page, kind, cells, right)
+				right, cells = page, nil
+				break
+			}
+
+			if kind == sqliteTableLeaf {
+				f.writePage(page, kind, cells[:n], 0)
+				parents = append(parents, sqliteCell{Child: page, RowID: cells[n-1].RowID})
+				cells = cells[n:]
+				continue
+			}
+
+			// The next cell moves up, leave at least one for the last page.
+			if n == len(cells)-1 {
+				n--
+			}
+			f.writePage(page, kind, cells[:n], cells[n].Child)
+			parent := cells[n]
+			parent.Child = page
+			parents = append(parents, parent)
+			cells = cells[n+1:]
+		}
+
+		kind, cells = interior, parents
+	}
+
+	f.writePage(root, kind, cells, right)
+}
+
+// sqliteRowIDs returns the rowid of each row of a table, its id column or
+// else its position.
+func sqliteRowIDs(table sqlTable) []int64 {
+	rowids := make([]int64, len(table.Rows))
+	for i, row := range table.Rows {
+		rowids[i] = int64(i + 1)
+		if table.Columns[0] == "id" {
+			rowids[i] = row[0].(int64)
+		}
+	}
+
+	return rowids
+}
+
+// writeTable writes the rows of a table as a b-tree rooted at root. The id
+// column is the rowid, the record stores NULL in its place.
+func (f *sqliteFile) writeTable(root uint32, table sqlTable) {
+	rowids := sqliteRowIDs(table)
+
+	var cells []sqliteCell
+	for i, row := range table.Rows {
+		values := append([]interface{}{}, row...)
+		if table.Columns[0] == "id" {
+			values[0] = nil
+		}
+
+		record := sqliteRecord(values)
+		body := append(sqliteVarint(uint64(len(record))), sqliteVarint(uint64(rowids[i]))...)
+		cells = append(cells, sqliteCell{RowID: rowids[i], Body: append(body, f.spill(record, false)...)})
+	}
+	sort.Slice(cells, func(i, j int) bool { return cells[i].RowID < cells[j].RowID })
+
+	f.writeTree(root, cells, false)
+}
+
+// writeIndex writes an index of a table as a b-tree rooted at root. Its
+// keys are the indexed columns followed by the rowid.
+func (f *sqliteFile) writeIndex(root uint32, index sqliteIndex, table sqlTable) {
+	rowids := sqliteRowIDs(table)
+
+	var keys [][]interface{}
+	for i, row := range table.Rows {
+		var key []interface{}
+		for _, column := range index.Columns {
+			for j, name := range table.Columns {
+				if name == column {
+					key = append(key, row[j])
+				}
+			}
+		}
+		keys = append(keys, append(key, rowids[i]))
+	}
+	sort.Slice(keys, func(i, j int) bool { return sqliteCompare(keys[i], keys[j]) < 0 })
+
+	var cells []sqliteCell
+	for _, key := range keys {
+		record := sqliteRecord(key)
+		cells = append(cells, sqliteCell{Body: append(sqliteVarint(uint64(len(record))), f.spill(record, true)...)})
+	}
+
+	f.writeTree(root, cells, true)
+}
+
+// exportSQLite converts the models of one or more platforms to a SQLite
+// database with the tables of sqlSchema.
+func exportSQLite(models []*Model) ([]byte, error) {
+	tables, err := sqlTables(models)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := make(map[string]string)
+	for _, statement := range strings.Split(sqlSchema, ";\n") {
+		statement = strings.TrimSpace(statement)
+		if fields := strings.Fields(statement); len(fields) > 2 && fields[0] == "CREATE" {
+			statements[fields[2]] = statement
+		}
+	}
+
+	f := &sqliteFile{}
+	f.allocate()
+
+	// The schema lists each table followed by its automatic indexes, then
+	// the other indexes, in the order SQLite creates them from sqlSchema.
+	schema := sqlTable{Name: "sqlite_schema", Columns: []string{"type", "name", "tbl_name", "rootpage", "sql"}}
+	for _, table := range tables {
+		statement, ok := statements[table.Name]
+		if ok != true {
+			return nil, fmt.Errorf("table %s is not in the schema", table.Name)
+		}
+
+		root := f.allocate()
+		f.writeTable(root, table)
+		schema.Rows = append(schema.Rows, []interface{}{"table", table.Name, table.Name, int64(root), statement})
+
+		for _, index := range sqliteIndexes {
+			if index.Table == table.Name && strings.HasPrefix(index.Name, "sqlite_autoindex_") {
+				root := f.allocate()
+				f.writeIndex(root, index, table)
+				schema.Rows = append(schema.Rows, []interface{}{"index", index.Name, table.Name, int64(root), nil})
+			}
+		}
+	}
+
+	for _, index := range sqliteIndexes {
+		for _, table := range tables {
+			if index.Table == table.Name && strings.HasPrefix(index.Name, "sqlite_autoindex_") != true {
+				root := f.allocate()
+				f.writeIndex(root, index, table)
+				schema.Rows = append(schema.Rows, []interface{}{"index", index.Name, table.Name, int64(root),
+					statements[index.Name]})
+			}
+		}
+	}
+
+	f.writeTable(1, schema)
+
+	header := f.pages[0]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], sqlitePageSize)
+	header[18], header[19] = 1, 1 // Rollback journal.
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1) // File change counter.
+	binary.BigEndian.PutUint32(header[28:], uint32(len(f.pages)))
+	binary.BigEndian.PutUint32(header[40:], 1) // Schema cookie.
+	binary.BigEndian.PutUint32(header[44:], 4) // Schema format.
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8.
+	binary.BigEndian.PutUint32(header[92:], 1) // Change counter the version is valid for.
+	binary.BigEndian.PutUint32(header[96:], 3008000)
+
+	var data []byte
+	for _, page := range f.pages {
+		data = append(data, page...)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,313 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SyzDescriptions are the parts of syzkaller's hand written syzlang
+// descriptions entrygen uses to enrich its model.
+type SyzDescriptions struct {
+	Calls     map[string][]SyzArg // Arguments of each call, without $variants.
+	Flags     map[string][]string // Flag sets by name.
+	Resources map[string]string   // Resources and the resource or int they're based on.
+}
+
+// SyzArg is one argument of a described call.
+type SyzArg struct {
+	Name string
+	Type string
+}
+
+var (
+	syzCallLine  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(\$[A-Za-z0-9_]+)?\(`)
+	syzFlagsLine = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.+)$`)
+	syzResLine   = regexp.MustCompile(`^resource\s+([A-Za-z_][A-Za-z0-9_]*)\[([A-Za-z0-9_]+)\]`)
+)
+
+// readSyzDescriptions reads every description file in paths, a path can be
+// a .txt file or a directory of them.
+func readSyzDescriptions(paths []string) (*SyzDescriptions, error) {
+	d := &SyzDescriptions{Calls: make(map[string][]SyzArg),
+		Flags:     make(map[string][]string),
+		Resources: make(map[string]string)}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			files, err = filepath.Glob(filepath.Join(path, "*.txt"))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			buf, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			d.parse(string(buf))
+		}
+	}
+
+	return d, nil
+}
+
+// parse adds the calls, flags and resources in one description file.
+// Structs and unions are skipped.
+func (d *SyzDescriptions) parse(text string) {
+	inStruct := false
+
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if inStruct {
+			if line[0] == '}' || line[0] == ']' {
+				inStruct = false
+			}
+			continue
+		}
+
+		if strings.HasSuffix(line, "{") || strings.HasSuffix(line, "[") {
+			inStruct = true
+			continue
+		}
+
+		if m := syzResLine.FindStringSubmatch(line); m != nil {
+			d.Resources[m[1]] = m[2]
+			continue
+		}
+
+		if m := syzCallLine.FindStringSubmatch(line); m != nil {
+			// The plain call wins over its $variants.
+			name := m[1]
+			if _, ok := d.Calls[name]; ok && m[2] != "" {
+				continue
+			}
+
+			// Types never contain parentheses, so the first one closes
+			// the arguments.
+			args := line[len(m[0]):]
+			if i := strings.Index(args, ")"); i >= 0 {
+				args = args[:i]
+			}
+
+			d.Calls[name] = parseSyzArgs(args)
+			continue
+		}
+
+		if m := syzFlagsLine.FindStringSubmatch(line); m != nil {
+			var values []string
+			for _, v := range strings.Split(m[2], ",") {
+				values = append(values, strings.TrimSpace(v))
+			}
+			d.Flags[m[1]] = values
+		}
+	}
+}
+
+// parseSyzArgs splits "fd fd, buf buffer[out], count len[buf]" into
+// arguments, commas inside brackets don't split.
+func parseSyzArgs(args string) []SyzArg {
+	var result []SyzArg
+	depth, start := 0, 0
+
+	for i := 0; i <= len(args); i++ {
+		if i < len(args) {
+			switch args[i] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			}
+			if args[i] != ',' || depth != 0 {
+				continue
+			}
+		}
+
+		fields := strings.Fields(args[start:i])
+		start = i + 1
+		if len(fields) < 2 {
+			continue
+		}
+		result = append(result, SyzArg{Name: fields[0], Type: strings.Join(fields[1:], " ")})
+	}
+
+	return result
+}
+
+// syzTypeParams splits "ptr[in, filename]" into ptr and [in filename].
+func syzTypeParams(typ string) (string, []string) {
+	i := strings.Index(typ, "[")
+	if i < 0 || strings.HasSuffix(typ, "]") != true {
+		return typ, nil
+	}
+
+	return typ[:i], splitSyzParams(typ[i+1 : len(typ)-1])
+}
+
+// splitSyzParams splits the params of a type on the commas that are not
+// nested in brackets.
+func splitSyzParams(params string) []string {
+	var result []string
+	depth, start := 0, 0
+
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				result = append(result, strings.TrimSpace(params[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(result, strings.TrimSpace(params[start:]))
+}
+
+// rootResource follows a resource to the resource it's based on, ie sock
+// to fd.
+func (d *SyzDescriptions) rootResource(name string) string {
+	for i := 0; i < 16; i++ {
+		parent, ok := d.Resources[name]
+		if ok != true {
+			break
+		}
+		if _, ok = d.Resources[parent]; ok != true {
+			return name
+		}
+		name = parent
+	}
+
+	return name
+}
+
+// enrichArg updates the kind, direction, flags and length link of an
+// argument from its described type. described maps the description's
+// argument names to the model's.
+func (d *SyzDescriptions) enrichArg(arg *Arg, typ string, described map[string]string) {
+	arg.SyzType = typ
+
+	base, params := syzTypeParams(typ)
+	switch base {
+	case "ptr", "ptr64":
+		arg.Kind = "pointer"
+		if len(params) > 0 {
+			arg.Direction = params[0]
+		}
+		if len(params) > 1 {
+			inner, _ := syzTypeParams(params[1])
+			switch inner {
+			case "filename":
+				arg.Kind = "path"
+			case "string":
+				arg.Kind = "string"
+			}
+		}
+	case "buffer":
+		arg.Kind = "pointer"
+		if len(params) > 0 {
+			arg.Direction = params[0]
+		}
+	case "vma", "vma64":
+		arg.Kind = "pointer"
+	case "flags":
+		arg.Kind = "flags"
+		if len(params) > 0 {
+			arg.Flags = params[0]
+		}
+	case "len", "bytesize", "bitsize":
+		arg.Kind = "len"
+		if len(params) > 0 {
+			arg.LenOf = described[params[0]]
+		}
+	default:
+		if _, ok := d.Resources[base]; ok {
+			switch d.rootResource(base) {
+			case "fd":
+				arg.Kind = "fd"
+			case "pid":
+				arg.Kind = "pid"
+			}
+		}
+	}
+}
+
+// enrichModel updates every entry that has a description and returns the
+// names of the ones that don't.
+func (d *SyzDescriptions) enrichModel(model *Model) []string {
+	var missing []string
+	if model.FlagSets == nil {
+		model.FlagSets = make(map[string][]string)
+	}
+
+	for i := range model.Entries {
+		entry := &model.Entries[i]
+		name := strings.TrimSpace(entry.SyscallName)
+
+		args, ok := d.Calls[name]
+		if ok != true {
+			missing = append(missing, name)
+			continue
+		}
+		entry.Described = true
+
+		// Match arguments by position, the names often differ.
+		described := make(map[string]string)
+		for j, a := range args {
+			if j < len(entry.ArgArray) {
+				described[a.Name] = entry.ArgArray[j].ArgName
+			}
+		}
+
+		for j := range entry.ArgArray {
+			if j >= len(args) {
+				break
+			}
+			d.enrichArg(&entry.ArgArray[j], args[j].Type, described)
+
+			if flags := entry.ArgArray[j].Flags; flags != "" {
+				if values, ok := d.Flags[flags]; ok {
+					model.FlagSets[flags] = values
+				}
+			}
+		}
+	}
+
+	sort.Strings(missing)
+	return missing
+}
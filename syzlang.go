@@ -0,0 +1,212 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Syzlang is the data for the syzkaller description templates.
+type Syzlang struct {
+	Platform Platform
+	Calls    []SyzCall
+	Consts   []SyzConst
+}
+
+// SyzCall is one syscall as a syzlang description, ie
+// read(fd fd, buf buffer[inout], nbyte len[buf]).
+type SyzCall struct {
+	Name   string
+	Number string
+	Args   []string // Each argument as "name type".
+	Ret    string   // The resource the call returns, if any.
+	Skip
+}
+
+type SyzConst struct {
+	Name  string
+	Value string
+}
+
+// Argument names that mean the int is a file descriptor.
+var syzFdNames = map[string]bool{"fd": true, "fdes": true, "fildes": true, "filedes": true,
+	"s": true, "sock": true, "kq": true, "fd1": true, "fd2": true}
+
+// Argument names that mean the int is the length of the pointer before it.
+var syzLenNames = map[string]bool{"len": true, "nbyte": true, "nbytes": true, "count": true,
+	"size": true, "bufsize": true, "buflen": true, "namelen": true, "datalen": true, "bufsz": true}
+
+// Pointer argument names that hold a path.
+var syzPathNames = map[string]bool{"path": true, "path1": true, "path2": true, "link": true,
+	"fname": true, "file": true, "target": true, "dirpath": true}
+
+// Syscalls that return a new file descriptor.
+var syzFdCalls = map[string]bool{"open": true, "openat": true, "open_nocancel": true,
+	"openat_nocancel": true, "open_extended": true, "socket": true, "accept": true,
+	"accept_nocancel": true, "accept4": true, "dup": true, "dup2": true, "kqueue": true,
+	"fhopen": true, "shm_open": true, "guarded_open_np": true, "open_dprotected_np": true}
+
+// guessString guesses that a pointer without a syzkaller description holds
+// a path or a string from its name or a const char * type.
+func guessString(arg Arg) bool {
+	return arg.Kind == "pointer" && (syzPathNames[arg.ArgName] || arg.CType == "const char *")
+}
+
+// syzIntType returns the syzlang int type with the same size as a C type.
+func syzIntType(ctype string) string {
+	switch strings.TrimPrefix(ctype, "const ") {
+	case "char", "u_char", "int8_t", "uint8_t":
+		return "int8"
+	case "short", "u_short", "int16_t", "uint16_t":
+		return "int16"
+	case "int", "u_int", "unsigned", "int32_t", "uint32_t", "u_int32_t", "uid_t", "gid_t",
+		"id_t", "mode_t", "key_t", "socklen_t", "idtype_t", "au_id_t", "au_asid_t", "dev_t":
+		return "int32"
+	case "int64_t", "uint64_t", "u_int64_t", "off_t", "user_off_t":
+		return "int64"
+	}
+
+	return "intptr"
+}
+
+// syzArgName names unnamed arguments and makes every name unique.
+func syzArgName(arg Arg, i int, used map[string]bool) string {
+	name := arg.ArgName
+	if name == "" {
+		name = fmt.Sprintf("a%d", i)
+	}
+
+	for used[name] {
+		name = name + "_"
+	}
+	used[name] = true
+
+	return name
+}
+
+// syzArgType maps an argument to a syzlang type using its kind, direction
+// and name. args are all the arguments so lengths can point at buffers.
+func syzArgType(args []Arg, names []string, i int) string {
+	arg := args[i]
+
+	// Types from syzkaller's own descriptions are used as they are, only
+	// the length links need the names used here.
+	if arg.LenOf != "" {
+		for j := range args {
+			if args[j].ArgName == arg.LenOf {
+				base, _ := syzTypeParams(arg.SyzType)
+				return base + "[" + names[j] + "]"
+			}
+		}
+	}
+
+	if arg.SyzType != "" && arg.Kind != "len" {
+		return arg.SyzType
+	}
+
+	switch arg.Kind {
+	case "pid":
+		return "pid"
+	case "port":
+		return "intptr"
+	case "pointer":
+		if syzPathNames[arg.ArgName] {
+			return "ptr[in, filename]"
+		}
+		if arg.CType == "const char *" {
+			return "ptr[in, string]"
+		}
+		return "buffer[" + arg.Direction + "]"
+	}
+
+	if syzFdNames[arg.ArgName] && syzIntType(arg.CType) == "int32" {
+		return "fd"
+	}
+
+	if arg.ArgName == "pid" && syzIntType(arg.CType) == "int32" {
+		return "pid"
+	}
+
+	if syzLenNames[arg.ArgName] && i > 0 && args[i-1].Kind == "pointer" {
+		return "len[" + names[i-1] + "]"
+	}
+
+	return syzIntType(arg.CType)
+}
+
+// syzlangCalls converts every entry of the model to a syzlang call.
+func syzlangCalls(model *Model) []SyzCall {
+	var calls []SyzCall
+	skips := model.skips(len(argSymbols))
+
+	for i, entry := range model.Entries {
+		name := strings.TrimSpace(entry.SyscallName)
+		call := SyzCall{Name: name, Number: entry.EntryNumber}
+
+		if call.Skipped = skips[i]; call.Skipped != "" {
+			calls = append(calls, call)
+			continue
+		}
+
+		used := make(map[string]bool)
+		names := make([]string, len(entry.ArgArray))
+		for i, arg := range entry.ArgArray {
+			names[i] = syzArgName(arg, i, used)
+		}
+
+		for i := range entry.ArgArray {
+			call.Args = append(call.Args, names[i]+" "+syzArgType(entry.ArgArray, names, i))
+		}
+
+		switch {
+		case syzFdCalls[name]:
+			call.Ret = "fd"
+		case entry.ReturnType == "pid_t":
+			call.Ret = "pid"
+		}
+
+		calls = append(calls, call)
+	}
+
+	return calls
+}
+
+// renderSyzlang generates syzkaller descriptions of every syscall and the
+// .const file with their numbers.
+func renderSyzlang(out *Output, model *Model, opts Options) {
+	s := Syzlang{Platform: model.Platform, Calls: syzlangCalls(model)}
+
+	for _, call := range s.Calls {
+		if call.Skipped == "" {
+			s.Consts = append(s.Consts, SyzConst{Name: "SYS_" + call.Name, Value: call.Number})
+		}
+	}
+
+	file := amalgamationName(model.Platform, ".txt")
+
+	for _, f := range []struct{ name, template string }{{file, "syzlang.txt"}, {file + ".const", "syzlang_const.txt"}} {
+		t, err := loadTemplate(opts.TemplateDir, model, f.template)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = out.execute(f.name, t, s); err != nil {
+			log.Fatal("Can't write syzlang file: ", err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// The default templates are compiled into the binary so entrygen
+// works no matter what directory it's run from.
+//
+//go:embed input/*.txt
+var defaultTemplates embed.FS
+
+// The partials every output template can use.
+var partialTemplates = []string{"warning.txt", "copyright.txt"}
+
+// readTemplate returns the contents of the template called name. A copy
+// of the template in dir takes precedence over the embedded default.
+func readTemplate(dir string, name string) (string, error) {
+	if dir != "" {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(buf), nil
+		}
+
+		if os.IsNotExist(err) != true {
+			return "", err
+		}
+	}
+
+	buf, err := defaultTemplates.ReadFile("input/" + name)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// templateFuncs returns the helper functions available to every template.
+func templateFuncs(model *Model) template.FuncMap {
+	return template.FuncMap{
+		"upper":        strings.ToUpper,
+		"lower":        strings.ToLower,
+		"cIdent":       cIdentifier,
+		"cString":      cString,
+		"join":         join,
+		"hex":          hex,
+		"pad":          pad,
+		"argKind":      argKind,
+		"argDirection": argDirection,
+		"isPointer":    isPointer,
+		"model":        func() *Model { return model },
+	}
+}
+
+// cString quotes str as a C string literal.
+func cString(str string) string {
+	var buffer bytes.Buffer
+	buffer.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch c {
+		case '"', '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteByte(c)
+		case '\n':
+			buffer.WriteString("\\n")
+		case '\t':
+			buffer.WriteString("\\t")
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&buffer, "\\%03o", c)
+				continue
+			}
+			buffer.WriteByte(c)
+		}
+	}
+	buffer.WriteByte('"')
+
+	return buffer.String()
+}
+
+// join is strings.Join with the separator first so it can be used at the
+// end of a pipeline, ie {{ .Names | join ", " }}.
+func join(sep string, items []string) string {
+	return strings.Join(items, sep)
+}
+
+// hex formats a number, or a string holding a number, in hexadecimal.
+func hex(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case int:
+		return fmt.Sprintf("0x%x", v), nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("0x%x", n), nil
+	}
+
+	return "", fmt.Errorf("hex: can't format %T", value)
+}
+
+// pad left justifies str in a field that is width characters wide.
+func pad(width int, str string) string {
+	if len(str) >= width {
+		return str
+	}
+
+	return str + strings.Repeat(" ", width-len(str))
+}
+
+// loadTemplate parses the template called name along with the copyright
+// and warning partials, all of them resolved from dir first. Any extra
+// templates are parsed into the same set so name can use their definitions.
+func loadTemplate(dir string, model *Model, name string, extra ...string) (*template.Template, error) {
+	text, err := readTemplate(dir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(name).Funcs(templateFuncs(model)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, partial := range append(extra, partialTemplates...) {
+		text, err = readTemplate(dir, partial)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = t.New(partial).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,231 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Trinity is the data for the Trinity syscall entry templates.
+type Trinity struct {
+	Year     string
+	Platform Platform
+	Header   string
+	Entries  []TrinityEntry
+	Lists    []TrinityList
+	Table    []TrinitySlot
+}
+
+// TrinityEntry is one syscall as a Trinity struct syscallentry.
+type TrinityEntry struct {
+	Name    string
+	Ident   string // The name of the struct, ie syscall_read.
+	Number  string
+	NumArgs int
+	Args    []TrinityArg
+	Flags   string
+	Group   string
+	Skip
+}
+
+type TrinityArg struct {
+	Index int
+	Name  string
+	Type  string
+	List  string // The flag list of an ARG_LIST argument.
+}
+
+// TrinityList is a flag set used by ARG_LIST arguments.
+type TrinityList struct {
+	Name   string
+	Values []string
+}
+
+// TrinitySlot is an entry of the number indexed syscall table.
+type TrinitySlot struct {
+	Number int
+	Ident  string
+}
+
+// Trinity gives every syscall up to six arguments.
+const trinityMaxArgs = 6
+
+// Syscalls Trinity should never call because they end the child.
+var trinityAvoid = map[string]bool{"exit": true, "sys_exit": true, "reboot": true,
+	"abort_with_payload": true, "terminate_with_payload": true, "thr_exit": true}
+
+// Syscalls that can block forever and need the watchdog alarm.
+var trinityNeedAlarm = map[string]bool{"read": true, "readv": true, "pread": true, "recvfrom": true,
+	"recvmsg": true, "accept": true, "accept4": true, "connect": true, "wait4": true, "waitid": true,
+	"select": true, "pselect": true, "poll": true, "ppoll": true, "kevent": true, "kevent64": true,
+	"sigsuspend": true, "sigwait": true, "msgrcv": true, "semop": true, "flock": true}
+
+var trinityGroups = map[string]string{"net": "GROUP_NET", "fs": "GROUP_VFS", "file": "GROUP_VFS",
+	"ipc": "GROUP_IPC", "memory": "GROUP_VM", "process": "GROUP_PROCESS", "signal": "GROUP_SIGNAL",
+	"time": "GROUP_TIME"}
+
+// trinityArgType maps an argument to one of Trinity's ARG_* types using
+// its kind, C type and name.
+func trinityArgType(args []Arg, i int, lists map[string][]string) string {
+	arg := args[i]
+
+	switch arg.Kind {
+	case "fd":
+		return "ARG_FD"
+	case "pid":
+		return "ARG_PID"
+	case "path":
+		return "ARG_PATHNAME"
+	case "flags":
+		if _, ok := lists[arg.Flags]; ok {
+			return "ARG_LIST"
+		}
+		return "ARG_UNDEFINED"
+	case "len":
+		for _, a := range args {
+			if a.ArgName == arg.LenOf && strings.Contains(a.CType, "sockaddr") {
+				return "ARG_SOCKADDRLEN"
+			}
+		}
+		return "ARG_LEN"
+	case "pointer", "string":
+		switch {
+		case guessString(arg):
+			return "ARG_PATHNAME"
+		case strings.Contains(arg.CType, "sockaddr"):
+			return "ARG_SOCKADDR"
+		case strings.Contains(arg.CType, "iovec"):
+			return "ARG_IOVEC"
+		case arg.Direction == "out":
+			return "ARG_NON_NULL_ADDRESS"
+		}
+		return "ARG_ADDRESS"
+	}
+
+	// Without a syzkaller description fall back to the names and types
+	// the syzlang output uses.
+	switch {
+	case syzFdNames[arg.ArgName] && syzIntType(arg.CType) == "int32":
+		return "ARG_FD"
+	case arg.ArgName == "pid":
+		return "ARG_PID"
+	case arg.CType == "mode_t" || arg.ArgName == "mode":
+		return "ARG_MODE_T"
+	case arg.ArgName == "iovcnt" && i > 0 && strings.Contains(args[i-1].CType, "iovec"):
+		return "ARG_IOVECLEN"
+	case syzLenNames[arg.ArgName] && i > 0 && args[i-1].Kind == "pointer":
+		return "ARG_LEN"
+	}
+
+	return "ARG_UNDEFINED"
+}
+
+// trinityFlags returns the flags of a syscall entry.
+func trinityFlags(name string) string {
+	switch {
+	case trinityAvoid[name]:
+		return "AVOID_SYSCALL"
+	case trinityNeedAlarm[name]:
+		return "NEED_ALARM"
+	}
+
+	return ""
+}
+
+// buildTrinity converts the model to Trinity syscall entries and the
+// number indexed table that points at them.
+func buildTrinity(model *Model) Trinity {
+	t := Trinity{Platform: model.Platform}
+	skips := model.skips(trinityMaxArgs)
+	used := make(map[string]bool)
+	idents := make(map[string]string)
+
+	for i, entry := range model.Entries {
+		name := strings.TrimSpace(entry.SyscallName)
+		e := TrinityEntry{Name: name,
+			Ident:   "syscall_" + cIdentifier(name),
+			Number:  entry.EntryNumber,
+			NumArgs: len(entry.ArgArray),
+			Flags:   trinityFlags(name),
+			Group:   trinityGroups[entry.Group]}
+
+		if e.Group == "" {
+			e.Group = "GROUP_NONE"
+		}
+
+		if e.Skipped = skips[i]; e.Skipped != "" {
+			t.Entries = append(t.Entries, e)
+			continue
+		}
+		idents[entry.EntryNumber] = e.Ident
+
+		for i, arg := range entry.ArgArray {
+			a := TrinityArg{Index: i,
+				Name: arg.ArgName,
+				Type: trinityArgType(entry.ArgArray, i, model.FlagSets)}
+
+			if a.Type == "ARG_LIST" {
+				a.List = cIdentifier(arg.Flags)
+				if used[arg.Flags] != true {
+					used[arg.Flags] = true
+					t.Lists = append(t.Lists, TrinityList{Name: a.List, Values: model.FlagSets[arg.Flags]})
+				}
+			}
+
+			e.Args = append(e.Args, a)
+		}
+
+		t.Entries = append(t.Entries, e)
+	}
+
+	// The first syscall with a number wins, like in the nextgen tables.
+	done := make(map[int]bool)
+	for _, slot := range model.Slots {
+		ident, ok := idents[strconv.Itoa(slot.Number)]
+		if slot.Status != statusOn || ok != true || done[slot.Number] {
+			continue
+		}
+		done[slot.Number] = true
+		t.Table = append(t.Table, TrinitySlot{Number: slot.Number, Ident: ident})
+	}
+
+	return t
+}
+
+// renderTrinity generates Trinity syscall entries, their table and a
+// header declaring them.
+func renderTrinity(out *Output, model *Model, opts Options) {
+	t := buildTrinity(model)
+	t.Header = "trinity_" + model.Platform.Name + ".h"
+
+	files := []struct{ name, template string }{
+		{"trinity_" + model.Platform.Name + ".c", "trinity.txt"},
+		{t.Header, "trinity_header.txt"}}
+
+	for _, f := range files {
+		tmpl, err := loadTemplate(opts.TemplateDir, model, f.template)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		t.Year = copyrightYear(out.path(f.name), opts)
+		if err = out.execute(f.name, tmpl, t); err != nil {
+			log.Fatal("Can't write Trinity file: ", err)
+		}
+	}
+}
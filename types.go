@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) 2016, Harrison Bowden, Minneapolis, MN
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose
+ * with or without fee is hereby granted, provided that the above copyright notice
+ * and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+ * REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY
+ * AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR
+ * CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS,
+ * WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT
+ * OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ **/
+
+package main
+
+import (
+	"strings"
+)
+
+// splitArgName splits a parameter from a syscall prototype, like
+// "const char *path", into it's C type and it's name.
+func splitArgName(param string) (string, string) {
+	param = strings.Join(strings.Fields(param), " ")
+	if param == "" || param == "void" {
+		return param, ""
+	}
+
+	// The name is the identifier at the end of the parameter.
+	i := len(param)
+	for i > 0 && isIdentChar(param[i-1]) {
+		i--
+	}
+
+	ctype := strings.TrimSpace(param[:i])
+	if ctype == "" {
+		// There is no name, only a type.
+		return param, ""
+	}
+
+	// Keep pointers attached to the type, ie "char *".
+	if strings.HasSuffix(ctype, "*") {
+		ctype = strings.TrimSpace(strings.TrimRight(ctype, "*")) + " " + strings.Repeat("*", strings.Count(ctype, "*"))
+	}
+
+	return ctype, param[i:]
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// isPointer reports whether a C type is a pointer, including the
+// kernel address types that user space sees as pointers.
+func isPointer(ctype string) bool {
+	if strings.Contains(ctype, "*") {
+		return true
+	}
+
+	switch ctype {
+	case "caddr_t", "user_addr_t":
+		return true
+	}
+
+	return false
+}
+
+// argKind returns what kind of value an argument of the C type holds,
+// one of pointer, pid, port or int.
+func argKind(ctype string) string {
+	if isPointer(ctype) {
+		return "pointer"
+	}
+
+	switch ctype {
+	case "pid_t":
+		return "pid"
+	case "mach_port_name_t":
+		return "port"
+	}
+
+	return "int"
+}
+
+// argDirection returns which way data moves through an argument of the C
+// type, in or inout. Only const pointers are known to be read only, other
+// pointers may be written by the kernel.
+func argDirection(ctype string) string {
+	if isPointer(ctype) && strings.HasPrefix(ctype, "const ") != true {
+		return "inout"
+	}
+
+	return "in"
+}